@@ -38,10 +38,12 @@ package main
 
 import (
 	"bufio"
+	"context"
 	"flag"
 	"fmt"
 	"log"
 	"os"
+	"path/filepath"
 	"runtime"
 	"runtime/pprof"
 	"runtime/trace"
@@ -56,11 +58,19 @@ var (
 	flagTrace       = flag.Bool("trace", false, "generate trace.")
 	flagLegacy      = flag.Bool("legacy", false, "call extern addr2line instead.")
 	flagAll         = flag.Bool("all", false, "addr2line for all pcs in dwarf debug_line.")
-	flagAllTracePCs = flag.Bool("all-trace-pc", false, "addr2line for all __sanitizer_cov_trace_ pcs in dwarf debug_line.")
+	flagAllTracePCs = flag.Bool("all-trace-pc", false, "addr2line for all __sanitizer_cov_trace_ pcs in dwarf debug_line, in all executable sections.")
 	flagAddress     = flag.Bool("a", false, "Like --addresses in gnu|llvm addr2line.")
 	flagFunction    = flag.Bool("f", false, "Like --functions in gnu|llvm addr2line.")
 	flagInline      = flag.Bool("i", false, "Like --inlines in gnu|llvm addr2line.")
+	flagDemangle    = flag.Bool("C", false, "Like --demangle in gnu|llvm addr2line. Print function names qualified by their namespaces and classes.")
 	flagFileName    = flag.String("e", "a.out", "Like -e in gnu|llvm addr2line. The default file is a.out.")
+	flagMmap        = flag.Bool("mmap", false, "map the file into memory instead of reading debug sections into the heap.")
+	flagExplain     = flag.Bool("explain", false, "show how each frame was chosen from .debug_info and .debug_line.")
+	flagIndexDir    = flag.String("index", "", "load or store a symbolization index keyed by build-id in this dir.")
+	flagSection     = flag.String("j", "", "Like -j in gnu addr2line. Read offsets relative to the specified section.")
+	flagDebugDirs   = flag.String("debug-file-directory", "", "Like --debug-file-directory in llvm-addr2line. List of directories searched for separate debug files.")
+	flagVerbose     = flag.Bool("verbose", false, "Like --verbose in llvm-symbolizer. Print function start, column and discriminator.")
+	flagPrefixMap   = flag.String("prefix-map", "", "Comma separated list of from=to. Source paths starting with from are printed starting with to instead.")
 
 	logger = log.New(os.Stdout, "", 0)
 )
@@ -92,10 +102,62 @@ func main() {
 		defer trace.Stop()
 	}
 
+	if *flagSection != "" && *flagLegacy {
+		fmt.Fprintf(os.Stderr, "-j is not supported with -legacy\n")
+		os.Exit(1)
+	}
+	var prefixMaps []dwarfparser.PrefixMap
+	if *flagPrefixMap != "" {
+		for _, m := range strings.Split(*flagPrefixMap, ",") {
+			from, to, ok := strings.Cut(m, "=")
+			if !ok {
+				fmt.Fprintf(os.Stderr, "bad -prefix-map %q, want from=to\n", m)
+				os.Exit(1)
+			}
+			prefixMaps = append(prefixMaps, dwarfparser.PrefixMap{From: from, To: to})
+		}
+	}
 	var pcs []uint64
-	var err error
+	var bin *dwarfparser.Binary
+	var provider dwarfparser.Provider
+	// With -legacy llvm-addr2line reads the binary, it is parsed here only
+	// to find the pcs of -all and -all-trace-pc.
+	if !*flagLegacy || *flagAll || *flagAllTracePCs {
+		open := dwarfparser.Open
+		if *flagMmap {
+			open = dwarfparser.OpenMmap
+		}
+		var err error
+		bin, err = open(*flagFileName)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "%v\n", err)
+			os.Exit(1)
+		}
+		defer bin.Close()
+		if *flagDebugDirs != "" {
+			bin.SetDebugDirs(filepath.SplitList(*flagDebugDirs)...)
+		}
+		bin.SetPrefixMaps(prefixMaps...)
+	}
+	if !*flagLegacy {
+		// Errors loading DWARF are reported by the lookups.
+		skipped, _ := bin.SkippedRelocations()
+		for _, s := range skipped {
+			fmt.Fprintf(os.Stderr, "warning: %v %v relocations not applied to %v\n", s.Count, s.Type, s.Section)
+		}
+		provider = bin
+		if *flagIndexDir != "" {
+			var err error
+			provider, err = bin.LoadIndex(context.Background(), *flagIndexDir)
+			if err != nil {
+				fmt.Fprintf(os.Stderr, "%v\n", err)
+				os.Exit(1)
+			}
+		}
+	}
 	if !*flagAll && !*flagAllTracePCs && len(flag.Args()) == 0 {
-		symb := NewSymbolizer()
+		symb := dwarfparser.NewLLVMAddr2line(*flagFileName)
+		symb.SetPrefixMaps(prefixMaps...)
 		defer symb.Close()
 		scanner := bufio.NewScanner(os.Stdin)
 		for {
@@ -117,7 +179,7 @@ func main() {
 				continue
 			}
 			if *flagLegacy {
-				frames, err := symb.SymbolizeArray(*flagFileName, pcs)
+				frames, err := symb.SymbolizeArray([]uint64{pc})
 				if err != nil {
 					fmt.Fprintf(os.Stderr, "failed to symbolize: %v\n", err)
 					os.Exit(1)
@@ -132,16 +194,17 @@ func main() {
 					logger.Printf("%v:%v\n", frame.File, frame.Line)
 				}
 			} else {
-				frames, err := dwarfparser.Addr2line(*flagFileName, pc)
+				frames, explain, err := addr2line(provider, bin, pc)
 				if err != nil {
 					fmt.Fprintf(os.Stderr, "%v\n", err)
 				}
-				println(frames, *flagAddress, *flagFunction, *flagInline)
+				println(pc, frames, explain, *flagAddress, *flagFunction, *flagInline, *flagDemangle, *flagVerbose)
 			}
 		}
 	}
 	if *flagAll || *flagAllTracePCs {
-		pcs, err = dwarfparser.FindAllPCs(*flagFileName, *flagAllTracePCs)
+		var err error
+		pcs, err = bin.FindAllPCs(*flagAllTracePCs)
 		if err != nil {
 			fmt.Fprintf(os.Stderr, "%v\n", err)
 		}
@@ -157,19 +220,67 @@ func main() {
 			pcs = append(pcs, pc)
 		}
 	}
+	if !*flagLegacy && !*flagExplain && *flagIndexDir == "" {
+		addrs := pcs
+		var addrErrs []error
+		if *flagSection != "" {
+			addrs = make([]uint64, len(pcs))
+			addrErrs = make([]error, len(pcs))
+			for i, pc := range pcs {
+				addrs[i], addrErrs[i] = bin.SectionAddr(*flagSection, pc)
+			}
+		}
+		frames, errs := bin.SymbolizeMany(addrs)
+		for i, pc := range pcs {
+			if addrErrs != nil && addrErrs[i] != nil {
+				fmt.Fprintf(os.Stderr, "failed to symbolize: %v\n", addrErrs[i])
+				continue
+			}
+			if errs[i] != nil {
+				fmt.Fprintf(os.Stderr, "failed to symbolize: %v\n", errs[i])
+			}
+			println(pc, frames[i], "", *flagAddress, *flagFunction, *flagInline, *flagDemangle, *flagVerbose)
+		}
+	} else if err := symbolizeParallel(provider, bin, prefixMaps, pcs); err != nil {
+		fmt.Fprintf(os.Stderr, "%v\n", err)
+		return
+	}
+	if !*flagLegacy {
+		for _, d := range bin.Diagnostics() {
+			fmt.Fprintf(os.Stderr, "warning: %v\n", d)
+		}
+	}
+	if *flagProfile {
+		memProfile, err := os.OpenFile("mem.prof.gz", os.O_CREATE|os.O_RDWR, 0644)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "%v\n", err)
+			os.Exit(1)
+		}
+		defer memProfile.Close()
+		err = pprof.WriteHeapProfile(memProfile)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "%v\n", err)
+			os.Exit(1)
+		}
+	}
+}
+
+// symbolizeParallel prints the frames of pcs in chunks on GOMAXPROCS workers.
+func symbolizeParallel(provider dwarfparser.Provider, bin *dwarfparser.Binary, prefixMaps []dwarfparser.PrefixMap, pcs []uint64) error {
 	procs := runtime.GOMAXPROCS(0)
 	errC := make(chan error, procs)
 	pcchan := make(chan []uint64, procs)
 	for p := 0; p < procs; p++ {
 		go func() {
-			var symb *Symbolizer
+			var symb *dwarfparser.LLVMAddr2line
 			if *flagLegacy {
-				symb = NewSymbolizer()
+				symb = dwarfparser.NewLLVMAddr2line(*flagFileName)
+				symb.SetPrefixMaps(prefixMaps...)
 				defer symb.Close()
 			}
 			for pcs := range pcchan {
 				if *flagLegacy {
-					frames, err := symb.SymbolizeArray(*flagFileName, pcs)
+					frames, err := symb.SymbolizeArray(pcs)
 					if err != nil {
 						errC <- fmt.Errorf("failed to symbolize: %w", err)
 						return
@@ -187,12 +298,11 @@ func main() {
 					}
 				} else {
 					for _, pc := range pcs {
-						frames, err := dwarfparser.Addr2line(*flagFileName, pc)
+						frames, explain, err := addr2line(provider, bin, pc)
 						if err != nil {
-							errC <- fmt.Errorf("failed to symbolize 0x%x: %w", pc, err)
-							return
+							fmt.Fprintf(os.Stderr, "failed to symbolize: %v\n", err)
 						}
-						println(frames, *flagAddress, *flagFunction, *flagInline)
+						println(pc, frames, explain, *flagAddress, *flagFunction, *flagInline, *flagDemangle, *flagVerbose)
 					}
 				}
 			}
@@ -210,41 +320,68 @@ func main() {
 	close(pcchan)
 	for p := 0; p < procs; p++ {
 		if err := <-errC; err != nil {
-			fmt.Fprintf(os.Stderr, "%v\n", err)
-			return
+			return err
 		}
 	}
-	if *flagProfile {
-		memProfile, err := os.OpenFile("mem.prof.gz", os.O_CREATE|os.O_RDWR, 0644)
-		if err != nil {
-			fmt.Fprintf(os.Stderr, "%v\n", err)
-			os.Exit(1)
-		}
-		defer memProfile.Close()
-		err = pprof.WriteHeapProfile(memProfile)
+	return nil
+}
+
+func addr2line(provider dwarfparser.Provider, bin *dwarfparser.Binary, pc uint64) ([]dwarfparser.Frame, string, error) {
+	if *flagSection != "" {
+		var err error
+		pc, err = bin.SectionAddr(*flagSection, pc)
 		if err != nil {
-			fmt.Fprintf(os.Stderr, "%v\n", err)
-			os.Exit(1)
+			return nil, "", err
 		}
 	}
+	if !*flagExplain {
+		frames, err := provider.Addr2line(pc)
+		return frames, "", err
+	}
+	frames, ex, err := bin.Explain(pc)
+	return frames, ex.String(), err
 }
 
-func println(frames []dwarfparser.Frame, flagAddress, flagFunction, flagInline bool) {
+func println(pc uint64, frames []dwarfparser.Frame, explain string, flagAddress, flagFunction, flagInline, flagDemangle, flagVerbose bool) {
 	if len(frames) < 1 {
 		return
 	}
 	var output string
 	if flagAddress {
-		output = fmt.Sprintf("0x%x\n", frames[0].PC)
+		output = fmt.Sprintf("0x%x\n", pc)
 	}
 	for _, frame := range frames {
 		if !flagInline && frame.Inline {
 			continue
 		}
+		name := frame.Func
+		if flagDemangle && frame.QualifiedFunc != "" {
+			name = frame.QualifiedFunc
+		}
 		if flagFunction {
-			output += fmt.Sprintf("%v\n", frame.Func)
+			output += fmt.Sprintf("%v\n", name)
+		}
+		if !flagVerbose {
+			output += fmt.Sprintf("%v:%v\n", frame.File, frame.Line)
+			continue
+		}
+		output += fmt.Sprintf("  Filename: %v\n", frame.File)
+		if frame.StartFile != "" {
+			output += fmt.Sprintf("  Function start filename: %v\n", frame.StartFile)
+		}
+		if frame.StartLine != 0 {
+			output += fmt.Sprintf("  Function start line: %v\n", frame.StartLine)
+		}
+		if frame.FuncEntry != 0 {
+			output += fmt.Sprintf("  Function start address: 0x%x\n", frame.FuncEntry)
+			output += fmt.Sprintf("  Function offset: %v+0x%x/0x%x\n", name, frame.FuncOffset, frame.FuncSize)
+		}
+		output += fmt.Sprintf("  Line: %v\n", frame.Line)
+		output += fmt.Sprintf("  Column: %v\n", frame.Column)
+		if frame.Discriminator != 0 {
+			output += fmt.Sprintf("  Discriminator: %v\n", frame.Discriminator)
 		}
-		output += fmt.Sprintf("%v:%v\n", frame.File, frame.Line)
 	}
+	output += explain
 	logger.Printf("%v", output)
 }
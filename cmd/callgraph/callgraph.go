@@ -76,6 +76,7 @@ import (
 	"bytes"
 	"flag"
 	"fmt"
+	"iter"
 	"log"
 	"math"
 	"os"
@@ -101,24 +102,30 @@ var (
 
 func main() {
 	flag.Parse()
-	funcs, err := dwarfparser.FindAllFuncs(*flagFileName)
+	bin, err := dwarfparser.Open(*flagFileName)
 	if err != nil {
 		fmt.Fprintf(os.Stderr, "%v\n", err)
+		os.Exit(1)
 	}
+	defer bin.Close()
 	if *flagVerbose {
-		for _, f := range funcs {
+		for f, err := range bin.Funcs() {
+			if err != nil {
+				fmt.Fprintf(os.Stderr, "%v\n", err)
+				break
+			}
 			logger.Printf("%v0x%x %v: %v\n", strings.Repeat(" ", f.Depth-1), f.Offset, f.Name, f.Depth)
 		}
 	}
 	if *flagCallgraph {
-		err := dot(funcs, *flagMaxLevel, *flagFormat, *flagCoveredFile, *flagStat, *flagVerbose)
+		err := dot(bin.Funcs(), *flagMaxLevel, *flagFormat, *flagCoveredFile, *flagStat, *flagVerbose)
 		if err != nil {
 			fmt.Fprintf(os.Stderr, "%v\n", err)
 		}
 	}
 }
 
-func dot(funcs []*dwarfparser.DWARFFunction, maxLevel int, format, coveredFile string, showStats, verbose bool) error {
+func dot(funcs iter.Seq2[*dwarfparser.DWARFFunction, error], maxLevel int, format, coveredFile string, showStats, verbose bool) error {
 	graph := graphviz.New()
 	defer graph.Close()
 	digraph, err := graph.Graph()
@@ -154,7 +161,10 @@ func dot(funcs []*dwarfparser.DWARFFunction, maxLevel int, format, coveredFile s
 	colors := []string{
 		"sienna1", "brown", "green", "cyan", "darkgreen", "tan1", "purple", "red", "yellow", "aquamarine", "bisque", "cadetblue",
 	}
-	for _, f := range funcs {
+	for f, err := range funcs {
+		if err != nil {
+			return err
+		}
 		if _, ok := stats[f.Depth]; !ok {
 			stats[f.Depth] = &covStats{
 				Depth: f.Depth,
@@ -220,9 +230,9 @@ func dot(funcs []*dwarfparser.DWARFFunction, maxLevel int, format, coveredFile s
 	if verbose {
 		logger.Printf("NumberNodes:%v, NumberEdges%v\n", digraph.NumberNodes(), digraph.NumberEdges())
 	}
-	for _, f := range funcs {
-		if _, ok := nodeWithEdges[f.Name]; !ok {
-			n, err := digraph.Node(f.Name)
+	for name := range uniqFunc {
+		if _, ok := nodeWithEdges[name]; !ok {
+			n, err := digraph.Node(name)
 			if err != nil {
 				return err
 			}
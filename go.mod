@@ -1,6 +1,6 @@
 module github.com/quic/dwarfparser
 
-go 1.20
+go 1.23
 
 require (
 	github.com/goccy/go-graphviz v0.1.1
@@ -0,0 +1,121 @@
+// =============================================================================
+//  @@-COPYRIGHT-START-@@
+//
+//  Copyright (c) 2024, Qualcomm Innovation Center, Inc. All rights reserved.
+//
+//  Redistribution and use in source and binary forms, with or without
+//  modification, are permitted provided that the following conditions are met:
+//
+//  1. Redistributions of source code must retain the above copyright notice,
+//     this list of conditions and the following disclaimer.
+//
+//  2. Redistributions in binary form must reproduce the above copyright notice,
+//     this list of conditions and the following disclaimer in the documentation
+//     and/or other materials provided with the distribution.
+//
+//  3. Neither the name of the copyright holder nor the names of its contributors
+//     may be used to endorse or promote products derived from this software
+//     without specific prior written permission.
+//
+//  THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS"
+//  AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE
+//  IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE
+//  ARE DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT HOLDER OR CONTRIBUTORS BE
+//  LIABLE FOR ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR
+//  CONSEQUENTIAL DAMAGES (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF
+//  SUBSTITUTE GOODS OR SERVICES; LOSS OF USE, DATA, OR PROFITS; OR BUSINESS
+//  INTERRUPTION) HOWEVER CAUSED AND ON ANY THEORY OF LIABILITY, WHETHER IN
+//  CONTRACT, STRICT LIABILITY, OR TORT (INCLUDING NEGLIGENCE OR OTHERWISE)
+//  ARISING IN ANY WAY OUT OF THE USE OF THIS SOFTWARE, EVEN IF ADVISED OF THE
+//  POSSIBILITY OF SUCH DAMAGE.
+//
+//  SPDX-License-Identifier: BSD-3-Clause
+//
+//  @@-COPYRIGHT-END-@@
+// =============================================================================
+
+package dwarfparser
+
+import (
+	"context"
+	"sort"
+)
+
+func SymbolizeMany(path string, pcs []uint64) ([][]Frame, []error) {
+	return SymbolizeManyContext(context.Background(), path, pcs)
+}
+
+func SymbolizeManyContext(ctx context.Context, path string, pcs []uint64) ([][]Frame, []error) {
+	b, release, err := openCached(path)
+	if err != nil {
+		return failMany(pcs, make([][]Frame, len(pcs)), make([]error, len(pcs)), err)
+	}
+	defer release()
+	return b.SymbolizeManyContext(ctx, pcs)
+}
+
+func (b *Binary) SymbolizeMany(pcs []uint64) ([][]Frame, []error) {
+	return b.SymbolizeManyContext(context.Background(), pcs)
+}
+
+// SymbolizeManyContext returns for each of pcs what Addr2line would, in the
+// order of pcs. The pcs are sorted and grouped by compile unit, and the
+// groups are symbolized in parallel, each CU being loaded once.
+func (b *Binary) SymbolizeManyContext(ctx context.Context, pcs []uint64) ([][]Frame, []error) {
+	frames := make([][]Frame, len(pcs))
+	errs := make([]error, len(pcs))
+	idx, err := b.compileUnitIndex()
+	if err != nil {
+		return failMany(pcs, frames, errs, err)
+	}
+	order := make([]int, len(pcs))
+	for i := range order {
+		order[i] = i
+	}
+	sort.Slice(order, func(i, j int) bool {
+		return pcs[order[i]] < pcs[order[j]]
+	})
+	var cus []*DWARFCompileUnit
+	var groups [][]int
+	groupOf := make(map[*DWARFCompileUnit]int)
+	for _, i := range order {
+		cu, ok := idx.find(pcs[i])
+		if !ok {
+			frames[i] = []Frame{unknownFrame(pcs[i])}
+			errs[i] = &LookupError{PC: pcs[i], Err: ErrNoCompileUnit}
+			continue
+		}
+		g, ok := groupOf[cu]
+		if !ok {
+			g = len(groups)
+			groupOf[cu] = g
+			cus = append(cus, cu)
+			groups = append(groups, nil)
+		}
+		groups[g] = append(groups[g], i)
+	}
+	err = b.forEachCompileUnit(ctx, cus, func(g int, cu *DWARFCompileUnit) error {
+		for _, i := range groups[g] {
+			if err := ctx.Err(); err != nil {
+				return err
+			}
+			frames[i], errs[i] = b.findFramesInCU(cu, pcs[i], nil)
+		}
+		return nil
+	})
+	if err != nil {
+		return failMany(pcs, frames, errs, err)
+	}
+	return frames, errs
+}
+
+// failMany sets err for the pcs not symbolized yet.
+func failMany(pcs []uint64, frames [][]Frame, errs []error, err error) ([][]Frame, []error) {
+	for i := range pcs {
+		if frames[i] == nil {
+			frames[i] = []Frame{unknownFrame(pcs[i])}
+			errs[i] = err
+		}
+	}
+	return frames, errs
+}
@@ -0,0 +1,240 @@
+// =============================================================================
+//  @@-COPYRIGHT-START-@@
+//
+//  Copyright (c) 2024, Qualcomm Innovation Center, Inc. All rights reserved.
+//
+//  Redistribution and use in source and binary forms, with or without
+//  modification, are permitted provided that the following conditions are met:
+//
+//  1. Redistributions of source code must retain the above copyright notice,
+//     this list of conditions and the following disclaimer.
+//
+//  2. Redistributions in binary form must reproduce the above copyright notice,
+//     this list of conditions and the following disclaimer in the documentation
+//     and/or other materials provided with the distribution.
+//
+//  3. Neither the name of the copyright holder nor the names of its contributors
+//     may be used to endorse or promote products derived from this software
+//     without specific prior written permission.
+//
+//  THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS"
+//  AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE
+//  IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE
+//  ARE DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT HOLDER OR CONTRIBUTORS BE
+//  LIABLE FOR ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR
+//  CONSEQUENTIAL DAMAGES (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF
+//  SUBSTITUTE GOODS OR SERVICES; LOSS OF USE, DATA, OR PROFITS; OR BUSINESS
+//  INTERRUPTION) HOWEVER CAUSED AND ON ANY THEORY OF LIABILITY, WHETHER IN
+//  CONTRACT, STRICT LIABILITY, OR TORT (INCLUDING NEGLIGENCE OR OTHERWISE)
+//  ARISING IN ANY WAY OUT OF THE USE OF THIS SOFTWARE, EVEN IF ADVISED OF THE
+//  POSSIBILITY OF SUCH DAMAGE.
+//
+//  SPDX-License-Identifier: BSD-3-Clause
+//
+//  @@-COPYRIGHT-END-@@
+// =============================================================================
+
+package dwarfparser
+
+import (
+	"bytes"
+	"debug/dwarf"
+	"debug/elf"
+	"fmt"
+	"io"
+	"os"
+	"sync"
+
+	cmap "github.com/orcaman/concurrent-map/v2"
+)
+
+// Binary is a handle of one ELF file. It owns the parsed DWARF, symbols and
+// all indexes built on top of them, which are released by Close.
+// A Binary is safe for concurrent use.
+type Binary struct {
+	Path string
+
+	file    *elf.File
+	closer  io.Closer
+	mapping []byte
+
+	mu            sync.Mutex
+	parallelism   int
+	prefixMaps    []PrefixMap
+	debugDirs     []string
+	dwarf         *dwarf.Data
+	symbols       []elf.Symbol
+	bases         []uint64
+	skippedRelocs []SkippedRelocation
+	diagnostics   map[DIEError]bool
+	units         []*DWARFCompileUnit
+	unitOffs      []dwarf.Offset
+	compileUnits  []*DWARFCompileUnit
+	cuIndex       *cuIndex
+	names         *nameIndex
+	alt           *Binary
+	altErr        error
+	altOpened     bool
+	dwo           *dwoState
+	debug         *Binary
+
+	subroutinesCMap cmap.ConcurrentMap[string, []*DWARFFunction]
+	subprogramsCMap cmap.ConcurrentMap[string, *rangeIndex[*DWARFFunction]]
+	lineFilesCMap   cmap.ConcurrentMap[string, []*dwarf.LineFile]
+	lineTablesCMap  cmap.ConcurrentMap[string, *lineTable]
+	namesCMap       cmap.ConcurrentMap[string, []NameEntry]
+	splitsCMap      cmap.ConcurrentMap[string, splitResult]
+	scopesCMap      cmap.ConcurrentMap[string, map[dwarf.Offset]string]
+}
+
+func Open(path string) (*Binary, error) {
+	b, _, err := openFile(path)
+	return b, err
+}
+
+// openFile is Open that also returns the FileInfo of the file it opened,
+// which stays the same even if path is replaced meanwhile.
+func openFile(path string) (*Binary, os.FileInfo, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, nil, err
+	}
+	info, err := f.Stat()
+	if err != nil {
+		f.Close()
+		return nil, nil, err
+	}
+	file, err := elf.NewFile(f)
+	if err != nil {
+		f.Close()
+		return nil, nil, err
+	}
+	b := newBinary(path, file)
+	b.closer = f
+	return b, info, nil
+}
+
+// OpenMmap is like Open but maps the file into memory, so that debug
+// sections, .text, .rela.* and .symtab are read in place instead of being
+// copied into the Go heap. It falls back to Open when the file can't be
+// mapped, e.g. when it is larger than the address space of a 32-bit host.
+//
+// Close unmaps the file. Nothing returned by the Binary, like the
+// *dwarf.Data of its DWARFCompileUnits, may be used after Close: reading
+// unmapped data is a fatal SIGSEGV, not an error.
+func OpenMmap(path string) (*Binary, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	info, err := f.Stat()
+	if err != nil {
+		return nil, err
+	}
+	data, err := mmapFile(f, info.Size())
+	if err != nil || len(data) == 0 {
+		return Open(path)
+	}
+	file, err := elf.NewFile(bytes.NewReader(data))
+	if err != nil {
+		munmap(data)
+		return nil, fmt.Errorf("%v: %w", path, err)
+	}
+	b := newBinary(path, file)
+	b.mapping = data
+	return b, nil
+}
+
+// NewBinary reads the ELF image from r, e.g. a file inside an archive or a
+// bytes.Reader over an in-memory image. name identifies the binary in
+// errors and results, it is not opened. Close does not close r.
+func NewBinary(r io.ReaderAt, name string) (*Binary, error) {
+	file, err := elf.NewFile(r)
+	if err != nil {
+		return nil, fmt.Errorf("%v: %w", name, err)
+	}
+	return newBinary(name, file), nil
+}
+
+func newBinary(name string, file *elf.File) *Binary {
+	return &Binary{
+		Path:            name,
+		file:            file,
+		subroutinesCMap: cmap.New[[]*DWARFFunction](),
+		subprogramsCMap: cmap.New[*rangeIndex[*DWARFFunction]](),
+		lineFilesCMap:   cmap.New[[]*dwarf.LineFile](),
+		lineTablesCMap:  cmap.New[*lineTable](),
+		namesCMap:       cmap.New[[]NameEntry](),
+		splitsCMap:      cmap.New[splitResult](),
+		scopesCMap:      cmap.New[map[dwarf.Offset]string](),
+	}
+}
+
+func (b *Binary) Close() error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.dwarf = nil
+	b.symbols = nil
+	b.bases = nil
+	b.skippedRelocs = nil
+	b.diagnostics = nil
+	b.units = nil
+	b.unitOffs = nil
+	b.compileUnits = nil
+	b.cuIndex = nil
+	b.names = nil
+	b.subroutinesCMap.Clear()
+	b.subprogramsCMap.Clear()
+	b.lineFilesCMap.Clear()
+	b.lineTablesCMap.Clear()
+	b.namesCMap.Clear()
+	b.scopesCMap.Clear()
+	for _, split := range b.splitsCMap.Items() {
+		if split.cu != nil {
+			split.cu.Binary.Close()
+		}
+	}
+	b.splitsCMap.Clear()
+	if b.dwo != nil && b.dwo.dwp != nil {
+		b.dwo.dwp.Close()
+	}
+	b.dwo = nil
+	if b.alt != nil {
+		b.alt.Close()
+		b.alt = nil
+	}
+	b.altErr = nil
+	if b.debug != nil && b.debug != b {
+		b.debug.Close()
+	}
+	b.debug = nil
+	b.altOpened = false
+	if b.file == nil {
+		return nil
+	}
+	err := b.file.Close()
+	b.file = nil
+	if b.closer != nil {
+		if err1 := b.closer.Close(); err == nil {
+			err = err1
+		}
+		b.closer = nil
+	}
+	if b.mapping != nil {
+		if err1 := munmap(b.mapping); err == nil {
+			err = err1
+		}
+		b.mapping = nil
+	}
+	return err
+}
+
+func (b *Binary) File() (*elf.File, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.file == nil {
+		return nil, fmt.Errorf("%v is closed", b.Path)
+	}
+	return b.file, nil
+}
@@ -0,0 +1,251 @@
+// =============================================================================
+//  @@-COPYRIGHT-START-@@
+//
+//  Copyright (c) 2024, Qualcomm Innovation Center, Inc. All rights reserved.
+//
+//  Redistribution and use in source and binary forms, with or without
+//  modification, are permitted provided that the following conditions are met:
+//
+//  1. Redistributions of source code must retain the above copyright notice,
+//     this list of conditions and the following disclaimer.
+//
+//  2. Redistributions in binary form must reproduce the above copyright notice,
+//     this list of conditions and the following disclaimer in the documentation
+//     and/or other materials provided with the distribution.
+//
+//  3. Neither the name of the copyright holder nor the names of its contributors
+//     may be used to endorse or promote products derived from this software
+//     without specific prior written permission.
+//
+//  THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS"
+//  AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE
+//  IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE
+//  ARE DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT HOLDER OR CONTRIBUTORS BE
+//  LIABLE FOR ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR
+//  CONSEQUENTIAL DAMAGES (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF
+//  SUBSTITUTE GOODS OR SERVICES; LOSS OF USE, DATA, OR PROFITS; OR BUSINESS
+//  INTERRUPTION) HOWEVER CAUSED AND ON ANY THEORY OF LIABILITY, WHETHER IN
+//  CONTRACT, STRICT LIABILITY, OR TORT (INCLUDING NEGLIGENCE OR OTHERWISE)
+//  ARISING IN ANY WAY OUT OF THE USE OF THIS SOFTWARE, EVEN IF ADVISED OF THE
+//  POSSIBILITY OF SUCH DAMAGE.
+//
+//  SPDX-License-Identifier: BSD-3-Clause
+//
+//  @@-COPYRIGHT-END-@@
+// =============================================================================
+
+package dwarfparser
+
+import (
+	"container/list"
+	"debug/elf"
+	"os"
+	"strings"
+	"sync"
+)
+
+// CacheStats describes the cache of Binaries shared by the path based
+// functions of this package.
+type CacheStats struct {
+	Binaries    int
+	Bytes       int64
+	MaxBinaries int
+	MaxBytes    int64
+	Hits        uint64
+	Misses      uint64
+	Evictions   uint64
+}
+
+type cacheEntry struct {
+	path    string
+	bin     *Binary
+	info    os.FileInfo
+	size    int64
+	refs    int
+	evicted bool
+	elem    *list.Element
+}
+
+type binaryCache struct {
+	mu          sync.Mutex
+	entries     map[string]*cacheEntry
+	lru         *list.List
+	bytes       int64
+	maxBinaries int
+	maxBytes    int64
+	hits        uint64
+	misses      uint64
+	evictions   uint64
+}
+
+var (
+	binaries = &binaryCache{
+		entries:     make(map[string]*cacheEntry),
+		lru:         list.New(),
+		maxBinaries: 8,
+	}
+)
+
+// SetCacheLimit bounds the cache used by the path based functions.
+// The least recently used binaries are dropped once either limit is
+// exceeded, 0 means no limit. The byte budget is compared against the
+// size of the debug sections and symbol tables of each binary.
+func SetCacheLimit(maxBinaries int, maxBytes int64) {
+	c := binaries
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.maxBinaries = maxBinaries
+	c.maxBytes = maxBytes
+	c.evict()
+}
+
+// Purge drops the cached Binary of path. It is closed as soon as no
+// function is using it any more.
+func Purge(path string) {
+	c := binaries
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if e, ok := c.entries[path]; ok {
+		c.remove(e)
+	}
+}
+
+// PurgeAll drops every cached Binary.
+func PurgeAll() {
+	c := binaries
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for _, e := range c.entries {
+		c.remove(e)
+	}
+}
+
+func Stats() CacheStats {
+	c := binaries
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return CacheStats{
+		Binaries:    len(c.entries),
+		Bytes:       c.bytes,
+		MaxBinaries: c.maxBinaries,
+		MaxBytes:    c.maxBytes,
+		Hits:        c.hits,
+		Misses:      c.misses,
+		Evictions:   c.evictions,
+	}
+}
+
+// openCached returns the Binary shared by the path based functions of
+// this package and a func to call once the caller is done with it.
+// A cached Binary is reused only as long as the file at path is still the
+// same file, with the same size and modification time.
+func openCached(path string) (*Binary, func(), error) {
+	info, err := os.Stat(path)
+	if err != nil {
+		return nil, nil, err
+	}
+	c := binaries
+	c.mu.Lock()
+	if e, ok := c.entries[path]; ok {
+		if sameFile(e.info, info) {
+			c.hits++
+			c.lru.MoveToFront(e.elem)
+			e.refs++
+			c.mu.Unlock()
+			return e.bin, c.releaseFunc(e), nil
+		}
+		c.remove(e)
+	}
+	c.misses++
+	c.mu.Unlock()
+
+	// The identity is that of the file actually opened, path may have been
+	// replaced since the Stat above.
+	b, info, err := openFile(path)
+	if err != nil {
+		return nil, nil, err
+	}
+	b.SetPrefixMaps(PrefixMaps()...)
+	b.SetDebugDirs(DebugDirs()...)
+	e := &cacheEntry{
+		path: path,
+		bin:  b,
+		info: info,
+		size: estimateSize(b.file),
+		refs: 1,
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if old, ok := c.entries[path]; ok {
+		c.remove(old)
+	}
+	e.elem = c.lru.PushFront(e)
+	c.entries[path] = e
+	c.bytes += e.size
+	c.evict()
+	return b, c.releaseFunc(e), nil
+}
+
+// each calls fn for every cached Binary.
+func (c *binaryCache) each(fn func(b *Binary)) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for _, e := range c.entries {
+		fn(e.bin)
+	}
+}
+
+func (c *binaryCache) releaseFunc(e *cacheEntry) func() {
+	var once sync.Once
+	return func() {
+		once.Do(func() {
+			c.mu.Lock()
+			defer c.mu.Unlock()
+			e.refs--
+			if e.refs == 0 && e.evicted {
+				e.bin.Close()
+			}
+		})
+	}
+}
+
+// evict must be called with c.mu held.
+func (c *binaryCache) evict() {
+	for c.lru.Len() > 1 {
+		if (c.maxBinaries <= 0 || c.lru.Len() <= c.maxBinaries) &&
+			(c.maxBytes <= 0 || c.bytes <= c.maxBytes) {
+			break
+		}
+		c.remove(c.lru.Back().Value.(*cacheEntry))
+		c.evictions++
+	}
+}
+
+// remove must be called with c.mu held.
+func (c *binaryCache) remove(e *cacheEntry) {
+	if e.evicted {
+		return
+	}
+	e.evicted = true
+	c.lru.Remove(e.elem)
+	delete(c.entries, e.path)
+	c.bytes -= e.size
+	if e.refs == 0 {
+		e.bin.Close()
+	}
+}
+
+func sameFile(a, b os.FileInfo) bool {
+	return os.SameFile(a, b) && a.Size() == b.Size() && a.ModTime().Equal(b.ModTime())
+}
+
+func estimateSize(f *elf.File) int64 {
+	var size int64
+	for _, s := range f.Sections {
+		if strings.HasPrefix(s.Name, ".debug_") || strings.HasPrefix(s.Name, ".zdebug_") ||
+			s.Type == elf.SHT_SYMTAB || s.Type == elf.SHT_STRTAB {
+			size += int64(s.Size)
+		}
+	}
+	return size
+}
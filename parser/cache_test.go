@@ -0,0 +1,174 @@
+// =============================================================================
+//  @@-COPYRIGHT-START-@@
+//
+//  Copyright (c) 2024, Qualcomm Innovation Center, Inc. All rights reserved.
+//
+//  Redistribution and use in source and binary forms, with or without
+//  modification, are permitted provided that the following conditions are met:
+//
+//  1. Redistributions of source code must retain the above copyright notice,
+//     this list of conditions and the following disclaimer.
+//
+//  2. Redistributions in binary form must reproduce the above copyright notice,
+//     this list of conditions and the following disclaimer in the documentation
+//     and/or other materials provided with the distribution.
+//
+//  3. Neither the name of the copyright holder nor the names of its contributors
+//     may be used to endorse or promote products derived from this software
+//     without specific prior written permission.
+//
+//  THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS"
+//  AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE
+//  IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE
+//  ARE DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT HOLDER OR CONTRIBUTORS BE
+//  LIABLE FOR ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR
+//  CONSEQUENTIAL DAMAGES (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF
+//  SUBSTITUTE GOODS OR SERVICES; LOSS OF USE, DATA, OR PROFITS; OR BUSINESS
+//  INTERRUPTION) HOWEVER CAUSED AND ON ANY THEORY OF LIABILITY, WHETHER IN
+//  CONTRACT, STRICT LIABILITY, OR TORT (INCLUDING NEGLIGENCE OR OTHERWISE)
+//  ARISING IN ANY WAY OUT OF THE USE OF THIS SOFTWARE, EVEN IF ADVISED OF THE
+//  POSSIBILITY OF SUCH DAMAGE.
+//
+//  SPDX-License-Identifier: BSD-3-Clause
+//
+//  @@-COPYRIGHT-END-@@
+// =============================================================================
+
+package dwarfparser
+
+import (
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func copyFile(t *testing.T, dst, src string) {
+	t.Helper()
+	in, err := os.Open(src)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer in.Close()
+	out, err := os.Create(dst)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := io.Copy(out, in); err != nil {
+		out.Close()
+		t.Fatal(err)
+	}
+	if err := out.Close(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// resetCache empties the cache for a test and restores the default limits
+// when it ends.
+func resetCache(t *testing.T) {
+	PurgeAll()
+	t.Cleanup(func() {
+		SetCacheLimit(8, 0)
+		PurgeAll()
+	})
+}
+
+func TestCacheEviction(t *testing.T) {
+	resetCache(t)
+	dir := t.TempDir()
+	for _, name := range []string{"c4.o", "c5.o", "cz.o"} {
+		copyFile(t, filepath.Join(dir, name), filepath.Join("testdata", name))
+	}
+	SetCacheLimit(2, 0)
+	start := Stats()
+	var held *Binary
+	var release func()
+	for i, tt := range []struct {
+		name      string
+		hit       bool
+		evictions uint64
+	}{
+		{"c4.o", false, 0},
+		{"c5.o", false, 0},
+		{"c4.o", true, 0},
+		// c5.o is the least recently used one.
+		{"cz.o", false, 1},
+		{"c4.o", true, 1},
+		{"c5.o", false, 2},
+		{"cz.o", false, 3},
+	} {
+		before := Stats()
+		b, rel, err := openCached(filepath.Join(dir, tt.name))
+		if err != nil {
+			t.Fatal(err)
+		}
+		// Keep the first c5.o while it is evicted.
+		if i == 1 {
+			held, release = b, rel
+		} else {
+			rel()
+		}
+		after := Stats()
+		if hit := after.Hits > before.Hits; hit != tt.hit {
+			t.Errorf("step %d: %v hit = %v, want %v", i, tt.name, hit, tt.hit)
+		}
+		if n := after.Evictions - start.Evictions; n != tt.evictions {
+			t.Errorf("step %d: %v evictions = %d, want %d", i, tt.name, n, tt.evictions)
+		}
+		if after.Binaries > 2 {
+			t.Errorf("step %d: %d binaries cached, want at most 2", i, after.Binaries)
+		}
+	}
+	if _, err := held.File(); err != nil {
+		t.Errorf("evicted Binary closed while in use: %v", err)
+	}
+	release()
+	if _, err := held.File(); err == nil {
+		t.Error("evicted Binary not closed once released")
+	}
+}
+
+func TestCacheStaleFile(t *testing.T) {
+	resetCache(t)
+	dir := t.TempDir()
+	path := filepath.Join(dir, "a.o")
+	copyFile(t, path, filepath.Join("testdata", "c4.o"))
+	unitName := func(b *Binary) string {
+		t.Helper()
+		for cu, err := range b.Units() {
+			if err != nil {
+				t.Fatal(err)
+			}
+			return cu.Name
+		}
+		return ""
+	}
+	old, release, err := openCached(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	release()
+	if name := unitName(old); name != "c.c" {
+		t.Fatalf("unit of %v is %q, want c.c", path, name)
+	}
+	// Replace the file the way a rebuild does.
+	copyFile(t, path+".tmp", filepath.Join("testdata", "cxx.o"))
+	if err := os.Rename(path+".tmp", path); err != nil {
+		t.Fatal(err)
+	}
+	before := Stats()
+	b, release, err := openCached(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer release()
+	if Stats().Misses != before.Misses+1 || b == old {
+		t.Error("replaced file served from the cache")
+	}
+	if name := unitName(b); name != "p.cc" {
+		t.Errorf("unit of the replaced %v is %q, want p.cc", path, name)
+	}
+	if _, err := old.File(); err == nil {
+		t.Error("stale Binary not closed")
+	}
+}
@@ -0,0 +1,236 @@
+// =============================================================================
+//  @@-COPYRIGHT-START-@@
+//
+//  Copyright (c) 2024, Qualcomm Innovation Center, Inc. All rights reserved.
+//
+//  Redistribution and use in source and binary forms, with or without
+//  modification, are permitted provided that the following conditions are met:
+//
+//  1. Redistributions of source code must retain the above copyright notice,
+//     this list of conditions and the following disclaimer.
+//
+//  2. Redistributions in binary form must reproduce the above copyright notice,
+//     this list of conditions and the following disclaimer in the documentation
+//     and/or other materials provided with the distribution.
+//
+//  3. Neither the name of the copyright holder nor the names of its contributors
+//     may be used to endorse or promote products derived from this software
+//     without specific prior written permission.
+//
+//  THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS"
+//  AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE
+//  IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE
+//  ARE DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT HOLDER OR CONTRIBUTORS BE
+//  LIABLE FOR ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR
+//  CONSEQUENTIAL DAMAGES (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF
+//  SUBSTITUTE GOODS OR SERVICES; LOSS OF USE, DATA, OR PROFITS; OR BUSINESS
+//  INTERRUPTION) HOWEVER CAUSED AND ON ANY THEORY OF LIABILITY, WHETHER IN
+//  CONTRACT, STRICT LIABILITY, OR TORT (INCLUDING NEGLIGENCE OR OTHERWISE)
+//  ARISING IN ANY WAY OUT OF THE USE OF THIS SOFTWARE, EVEN IF ADVISED OF THE
+//  POSSIBILITY OF SUCH DAMAGE.
+//
+//  SPDX-License-Identifier: BSD-3-Clause
+//
+//  @@-COPYRIGHT-END-@@
+// =============================================================================
+
+package dwarfparser
+
+import (
+	"fmt"
+	"sort"
+)
+
+// maxUnitHeaderSize is the largest unit header before the first DIE, a
+// 64-bit DWARF 5 skeleton unit.
+const maxUnitHeaderSize = 32
+
+type addrRange[T comparable] struct {
+	Start uint64
+	End   uint64
+	Val   T
+}
+
+type cuRange = addrRange[*DWARFCompileUnit]
+
+// rangeIndex maps addresses to values. Its ranges are sorted and don't
+// overlap: where input ranges overlap, the one starting first keeps the
+// shared addresses, ties are broken by the input order.
+type rangeIndex[T comparable] struct {
+	ranges []addrRange[T]
+}
+
+type cuIndex = rangeIndex[*DWARFCompileUnit]
+
+func newRangeIndex[T comparable](ranges []addrRange[T]) *rangeIndex[T] {
+	sort.SliceStable(ranges, func(i, j int) bool {
+		return ranges[i].Start < ranges[j].Start
+	})
+	idx := &rangeIndex[T]{}
+	var covered uint64
+	for _, r := range ranges {
+		if r.Start < covered {
+			r.Start = covered
+		}
+		if r.Start >= r.End {
+			continue
+		}
+		covered = r.End
+		if n := len(idx.ranges); n > 0 && idx.ranges[n-1].Val == r.Val && idx.ranges[n-1].End == r.Start {
+			idx.ranges[n-1].End = r.End
+			continue
+		}
+		idx.ranges = append(idx.ranges, r)
+	}
+	return idx
+}
+
+// newCUIndex breaks ties by the lower CU offset.
+func newCUIndex(ranges []cuRange) *cuIndex {
+	sort.SliceStable(ranges, func(i, j int) bool {
+		if ranges[i].Val.Entry.Offset != ranges[j].Val.Entry.Offset {
+			return ranges[i].Val.Entry.Offset < ranges[j].Val.Entry.Offset
+		}
+		return ranges[i].End > ranges[j].End
+	})
+	return newRangeIndex(ranges)
+}
+
+func (idx *rangeIndex[T]) find(pc uint64) (T, bool) {
+	i := sort.Search(len(idx.ranges), func(i int) bool {
+		return pc < idx.ranges[i].Start
+	})
+	if i == 0 || pc >= idx.ranges[i-1].End {
+		var zero T
+		return zero, false
+	}
+	return idx.ranges[i-1].Val, true
+}
+
+// compileUnitIndex returns the address index of the compile units of b. It
+// is seeded from .debug_aranges, CUs missing there are added by their
+// DW_AT_ranges or DW_AT_low_pc/DW_AT_high_pc.
+func (b *Binary) compileUnitIndex() (*cuIndex, error) {
+	b.mu.Lock()
+	idx := b.cuIndex
+	b.mu.Unlock()
+	if idx != nil {
+		return idx, nil
+	}
+	cus, err := b.FindAllCompileUnits()
+	if err != nil {
+		return nil, err
+	}
+	ranges, err := b.readAranges(cus)
+	if err != nil {
+		// Fall back to the ranges of the CUs.
+		ranges = nil
+	}
+	seen := make(map[*DWARFCompileUnit]bool)
+	for _, r := range ranges {
+		seen[r.Val] = true
+	}
+	for _, cu := range cus {
+		if seen[cu] {
+			continue
+		}
+		for _, r := range cu.Ranges {
+			ranges = append(ranges, cuRange{
+				Start: r[0],
+				End:   r[1],
+				Val:   cu,
+			})
+		}
+	}
+	idx = newCUIndex(ranges)
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.cuIndex == nil {
+		b.cuIndex = idx
+	}
+	return b.cuIndex, nil
+}
+
+// readAranges returns the ranges listed in .debug_aranges. Sets of units
+// not in cus are dropped.
+func (b *Binary) readAranges(cus []*DWARFCompileUnit) ([]cuRange, error) {
+	db, f, err := b.debugFile()
+	if err != nil {
+		return nil, err
+	}
+	s := debugSection(f, ".debug_aranges")
+	if s == nil || hasDebugRelocations(f) {
+		return nil, nil
+	}
+	data, err := db.sectionData(s)
+	if err != nil {
+		return nil, err
+	}
+	order := f.ByteOrder
+	var ranges []cuRange
+	for off := 0; off < len(data); {
+		set := data[off:]
+		if len(set) < 4 {
+			return nil, fmt.Errorf("malformed .debug_aranges at 0x%x", off)
+		}
+		length, n, offSize := uint64(order.Uint32(set)), 4, 4
+		if length == 0xffffffff {
+			if len(set) < 12 {
+				return nil, fmt.Errorf("malformed .debug_aranges at 0x%x", off)
+			}
+			length, n, offSize = order.Uint64(set[4:]), 12, 8
+		}
+		if length > uint64(len(set)-n) || length < uint64(2+offSize+2) {
+			return nil, fmt.Errorf("malformed .debug_aranges at 0x%x", off)
+		}
+		set = set[:n+int(length)]
+		p := n + 2
+		var infoOff uint64
+		if offSize == 4 {
+			infoOff = uint64(order.Uint32(set[p:]))
+		} else {
+			infoOff = order.Uint64(set[p:])
+		}
+		p += offSize
+		addrSize, segSize := int(set[p]), int(set[p+1])
+		p += 2
+		if segSize != 0 || (addrSize != 4 && addrSize != 8) {
+			return nil, fmt.Errorf("unsupported .debug_aranges at 0x%x", off)
+		}
+		// Tuples are aligned to their size from the start of the set.
+		tuple := 2 * addrSize
+		p = (p + tuple - 1) / tuple * tuple
+		cu := compileUnitAt(cus, infoOff)
+		for ; p+tuple <= len(set); p += tuple {
+			var addr, size uint64
+			if addrSize == 4 {
+				addr, size = uint64(order.Uint32(set[p:])), uint64(order.Uint32(set[p+4:]))
+			} else {
+				addr, size = order.Uint64(set[p:]), order.Uint64(set[p+8:])
+			}
+			if addr == 0 && size == 0 {
+				break
+			}
+			if cu != nil && size != 0 && addr+size > addr {
+				ranges = append(ranges, cuRange{
+					Start: addr,
+					End:   addr + size,
+					Val:   cu,
+				})
+			}
+		}
+		off += n + int(length)
+	}
+	return ranges, nil
+}
+
+// compileUnitAt returns the CU of the unit header at off in .debug_info.
+func compileUnitAt(cus []*DWARFCompileUnit, off uint64) *DWARFCompileUnit {
+	i := sort.Search(len(cus), func(i int) bool {
+		return uint64(cus[i].Entry.Offset) > off
+	})
+	if i == len(cus) || uint64(cus[i].Entry.Offset)-off > maxUnitHeaderSize {
+		return nil
+	}
+	return cus[i]
+}
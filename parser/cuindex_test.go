@@ -0,0 +1,148 @@
+// =============================================================================
+//  @@-COPYRIGHT-START-@@
+//
+//  Copyright (c) 2024, Qualcomm Innovation Center, Inc. All rights reserved.
+//
+//  Redistribution and use in source and binary forms, with or without
+//  modification, are permitted provided that the following conditions are met:
+//
+//  1. Redistributions of source code must retain the above copyright notice,
+//     this list of conditions and the following disclaimer.
+//
+//  2. Redistributions in binary form must reproduce the above copyright notice,
+//     this list of conditions and the following disclaimer in the documentation
+//     and/or other materials provided with the distribution.
+//
+//  3. Neither the name of the copyright holder nor the names of its contributors
+//     may be used to endorse or promote products derived from this software
+//     without specific prior written permission.
+//
+//  THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS"
+//  AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE
+//  IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE
+//  ARE DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT HOLDER OR CONTRIBUTORS BE
+//  LIABLE FOR ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR
+//  CONSEQUENTIAL DAMAGES (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF
+//  SUBSTITUTE GOODS OR SERVICES; LOSS OF USE, DATA, OR PROFITS; OR BUSINESS
+//  INTERRUPTION) HOWEVER CAUSED AND ON ANY THEORY OF LIABILITY, WHETHER IN
+//  CONTRACT, STRICT LIABILITY, OR TORT (INCLUDING NEGLIGENCE OR OTHERWISE)
+//  ARISING IN ANY WAY OUT OF THE USE OF THIS SOFTWARE, EVEN IF ADVISED OF THE
+//  POSSIBILITY OF SUCH DAMAGE.
+//
+//  SPDX-License-Identifier: BSD-3-Clause
+//
+//  @@-COPYRIGHT-END-@@
+// =============================================================================
+
+package dwarfparser
+
+import (
+	"bytes"
+	"debug/elf"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// testdata/inl has .debug_aranges, with a set for inl1.c at 0x401000-0x401012
+// and one for inl2.c at 0x401012-0x40101d.
+// testdata/inl.gdbindex is linked from the same sources without it.
+func TestCompileUnitByAddr(t *testing.T) {
+	type lookup struct {
+		pc uint64
+		cu string
+	}
+	for _, tt := range []struct {
+		name    string
+		file    string
+		patch   func(t *testing.T, data []byte)
+		aranges int
+		lookups []lookup
+	}{
+		{
+			name:    "aranges",
+			file:    "inl",
+			aranges: 2,
+			lookups: []lookup{
+				{0x400fff, ""},
+				{0x401000, "inl1.c"},
+				{0x401011, "inl1.c"},
+				{0x401012, "inl2.c"},
+				{0x40101c, "inl2.c"},
+				{0x40101d, ""},
+			},
+		},
+		{
+			// The set of inl1.c is one byte longer than the unit.
+			name: "aranges first",
+			file: "inl",
+			patch: func(t *testing.T, data []byte) {
+				f, err := elf.NewFile(bytes.NewReader(data))
+				if err != nil {
+					t.Fatal(err)
+				}
+				// The size of the first tuple follows the header of
+				// 12 bytes padded to 16 and its address.
+				s := f.Section(".debug_aranges")
+				f.ByteOrder.PutUint64(data[s.Offset+24:], 0x13)
+			},
+			aranges: 2,
+			lookups: []lookup{
+				{0x401011, "inl1.c"},
+				{0x401012, "inl1.c"},
+				{0x401013, "inl2.c"},
+				{0x40101d, ""},
+			},
+		},
+		{
+			name: "unit ranges",
+			file: "inl.gdbindex",
+			lookups: []lookup{
+				{0x4000e7, ""},
+				{0x4000e8, "inl1.c"},
+				{0x4000f9, "inl1.c"},
+				{0x4000fa, "inl2.c"},
+				{0x400104, "inl2.c"},
+				{0x400105, ""},
+			},
+		},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			data, err := os.ReadFile(filepath.Join("testdata", tt.file))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if tt.patch != nil {
+				tt.patch(t, data)
+			}
+			b, err := NewBinary(bytes.NewReader(data), tt.file)
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer b.Close()
+			cus, err := b.FindAllCompileUnits()
+			if err != nil {
+				t.Fatal(err)
+			}
+			ranges, err := b.readAranges(cus)
+			if err != nil || len(ranges) != tt.aranges {
+				t.Errorf("readAranges() = %v, %v, want %d ranges", ranges, err, tt.aranges)
+			}
+			for _, l := range tt.lookups {
+				cu, err := b.GetCompileUnitByAddr(l.pc)
+				if l.cu == "" {
+					if !errors.Is(err, ErrNoCompileUnit) {
+						t.Errorf("GetCompileUnitByAddr(0x%x) = %v, want %v", l.pc, err, ErrNoCompileUnit)
+					}
+					continue
+				}
+				if err != nil {
+					t.Errorf("GetCompileUnitByAddr(0x%x): %v", l.pc, err)
+				} else if cu.Name != l.cu {
+					t.Errorf("GetCompileUnitByAddr(0x%x) = %v, want %v", l.pc, cu.Name, l.cu)
+				}
+			}
+		})
+	}
+}
@@ -37,209 +37,208 @@
 package dwarfparser
 
 import (
+	"context"
 	"debug/dwarf"
 	"fmt"
-	"sort"
-
-	cmap "github.com/orcaman/concurrent-map/v2"
-)
-
-var (
-	allSubroutinesCMap = cmap.New[[]*DWARFFunction]()
-	compileUnitsCMap   = cmap.New[[]*DWARFCompileUnit]()
 )
 
 func GetCompileUnitByAddr(path string, pc uint64) (*DWARFCompileUnit, error) {
-	cus, err := FindAllCompileUnits(path)
+	b, release, err := openCached(path)
 	if err != nil {
 		return nil, err
 	}
-	type Range struct {
-		Start uint64
-		End   uint64
-		CU    *DWARFCompileUnit
-	}
-	var ranges []*Range
-	for _, cu := range cus {
-		for _, r := range cu.Ranges {
-			ranges = append(ranges, &Range{
-				Start: r[0],
-				End:   r[1],
-				CU:    cu,
-			})
-		}
+	defer release()
+	return b.GetCompileUnitByAddr(pc)
+}
+
+func (b *Binary) GetCompileUnitByAddr(pc uint64) (*DWARFCompileUnit, error) {
+	idx, err := b.compileUnitIndex()
+	if err != nil {
+		return nil, err
 	}
-	sort.Slice(ranges, func(i, j int) bool {
-		return ranges[i].End < ranges[j].End
-	})
-	idx := sort.Search(len(ranges), func(i int) bool {
-		return pc < ranges[i].Start
-	})
-	if idx == 0 || idx > len(ranges) {
-		return nil, fmt.Errorf("not find CompileUnit for pc 0x%x", pc)
+	cu, ok := idx.find(pc)
+	if !ok {
+		return nil, &LookupError{PC: pc, Err: ErrNoCompileUnit}
 	}
-	return ranges[idx-1].CU, nil
+	return cu, nil
 }
 
 func FindAllCompileUnits(path string) ([]*DWARFCompileUnit, error) {
-	if e, ok := compileUnitsCMap.Get(path); ok {
-		return e, nil
+	b, release, err := openCached(path)
+	if err != nil {
+		return nil, err
 	}
-	di, err := DWARF(path)
+	defer release()
+	return b.FindAllCompileUnits()
+}
+
+func (b *Binary) FindAllCompileUnits() ([]*DWARFCompileUnit, error) {
+	b.mu.Lock()
+	cus := b.compileUnits
+	b.mu.Unlock()
+	if cus != nil {
+		return cus, nil
+	}
+	units, err := b.allUnits()
 	if err != nil {
 		return nil, err
 	}
-	var cus []*DWARFCompileUnit
-	for r := di.Reader(); ; {
-		ent, err := r.Next()
-		if err != nil {
-			return nil, err
-		}
-		if ent == nil {
-			break
-		}
-		if ent.Tag != dwarf.TagCompileUnit {
-			return nil, fmt.Errorf("found unexpected tag %v on top level", ent.Tag)
-		}
-		attrName := ent.Val(dwarf.AttrName)
-		if attrName == nil {
-			continue
-		}
-		attrCompDir := ent.Val(dwarf.AttrCompDir)
-		ranges, err := di.Ranges(ent)
-		if err != nil {
-			return nil, err
-		}
-		cus = append(cus, &DWARFCompileUnit{
-			FilePath: path,
-			Dwarf:    di,
-			Entry:    ent,
-			Name:     attrName.(string),
-			CompDir:  attrCompDir.(string),
-			Ranges:   ranges,
-		})
-		r.SkipChildren()
-	}
-	var finalCUs []*DWARFCompileUnit
-	for _, cu := range cus {
-		if len(cu.Ranges) == 0 {
-			continue
+	for _, u := range units {
+		if u.isCompileUnit() {
+			cus = append(cus, u)
 		}
-		finalCUs = append(finalCUs, cu)
 	}
-	sort.Slice(finalCUs, func(i, j int) bool {
-		return finalCUs[i].Entry.Offset < finalCUs[j].Entry.Offset
-	})
-	compileUnitsCMap.Set(path, finalCUs)
-	return finalCUs, nil
+	b.mu.Lock()
+	b.compileUnits = cus
+	b.mu.Unlock()
+	return cus, nil
 }
 
 func FindAllFuncsInCUByAddr(path string, pc uint64) ([]*DWARFFunction, error) {
-	cu, err := GetCompileUnitByAddr(path, pc)
+	b, release, err := openCached(path)
+	if err != nil {
+		return nil, err
+	}
+	defer release()
+	return b.FindAllFuncsInCUByAddr(pc)
+}
+
+func (b *Binary) FindAllFuncsInCUByAddr(pc uint64) ([]*DWARFFunction, error) {
+	cu, err := b.GetCompileUnitByAddr(pc)
+	if err != nil {
+		return nil, err
+	}
+	return cu.getFuncs()
+}
+
+func FindAllFuncs(path string) ([]*DWARFFunction, error) {
+	return FindAllFuncsContext(context.Background(), path)
+}
+
+func FindAllFuncsContext(ctx context.Context, path string) ([]*DWARFFunction, error) {
+	b, release, err := openCached(path)
 	if err != nil {
 		return nil, err
 	}
-	k := fmt.Sprintf("%v-%v", path, cu.Entry.Offset)
-	if e, ok := allSubroutinesCMap.Get(k); ok {
+	defer release()
+	return b.FindAllFuncsContext(ctx)
+}
+
+func (b *Binary) FindAllFuncs() ([]*DWARFFunction, error) {
+	return b.FindAllFuncsContext(context.Background())
+}
+
+// FindAllFuncsContext returns the functions of all compile units, ordered by
+// the offset of their compile unit and then by DIE offset.
+func (b *Binary) FindAllFuncsContext(ctx context.Context) ([]*DWARFFunction, error) {
+	if e, ok := b.subroutinesCMap.Get("all"); ok {
 		return e, nil
 	}
-	funcs, err := cu.findAllFuncs()
+	cus, err := b.FindAllCompileUnits()
+	if err != nil {
+		return nil, err
+	}
+	results := make([][]*DWARFFunction, len(cus))
+	err = b.forEachCompileUnit(ctx, cus, func(i int, cu *DWARFCompileUnit) error {
+		funcs, err := cu.getFuncs()
+		results[i] = funcs
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	var funcs []*DWARFFunction
+	for _, res := range results {
+		funcs = append(funcs, res...)
+	}
+	b.subroutinesCMap.Set("all", funcs)
+	return funcs, nil
+}
+
+// FuncsByName returns the subprograms and inlined subroutines whose name,
+// qualified name or linkage name is name.
+func (b *Binary) FuncsByName(name string) ([]*DWARFFunction, error) {
+	funcs, err := b.FindAllFuncs()
 	if err != nil {
 		return nil, err
 	}
 	var finalFuncs []*DWARFFunction
 	for _, f := range funcs {
-		if len(f.Ranges) == 0 {
-			continue
+		if f.Name == name || f.QualifiedName == name || f.LinkageName == name {
+			finalFuncs = append(finalFuncs, f)
 		}
-		finalFuncs = append(finalFuncs, f)
 	}
-	allSubroutinesCMap.Set(k, finalFuncs)
 	return finalFuncs, nil
 }
 
-func FindAllFuncs(path string) ([]*DWARFFunction, error) {
-	if e, ok := allSubroutinesCMap.Get(path); ok {
-		return e, nil
-	}
-	type funcResult struct {
-		funcs []*DWARFFunction
-		err   error
-	}
-	var funcs []*DWARFFunction
-	cus, err := FindAllCompileUnits(path)
+// GetSubprogramByAddr returns the subprogram of cu with a range containing
+// pc. Ranges are matched one by one, so the gap between the parts of a
+// hot/cold split function belongs to whatever is placed there.
+func (cu *DWARFCompileUnit) GetSubprogramByAddr(pc uint64) (*DWARFFunction, error) {
+	idx, err := cu.subprogramIndex()
 	if err != nil {
 		return nil, err
 	}
-	funcC := make(chan funcResult, len(cus))
-	for _, cu := range cus {
-		go func(cu *DWARFCompileUnit) {
-			var res funcResult
-			funcs1, err := cu.findAllFuncs()
-			if err != nil {
-				res.err = err
-			}
-			res.funcs = append(res.funcs, funcs1...)
-			funcC <- res
-		}(cu)
+	sp, ok := idx.find(pc)
+	if !ok {
+		return nil, &LookupError{PC: pc, Err: ErrNoSubprogram}
 	}
-	for _, cu := range cus {
-		res := <-funcC
-		if err := res.err; err != nil {
-			return nil, err
-		}
-		k := fmt.Sprintf("%v-%v", path, cu.Entry.Offset)
-		allSubroutinesCMap.Set(k, res.funcs)
-		funcs = append(funcs, res.funcs...)
+	return sp, nil
+}
+
+func (cu *DWARFCompileUnit) subprogramIndex() (*rangeIndex[*DWARFFunction], error) {
+	k := fmt.Sprintf("%v", cu.Entry.Offset)
+	if e, ok := cu.Binary.subprogramsCMap.Get(k); ok {
+		return e, nil
 	}
-	allSubroutinesCMap.Set(path, funcs)
-	return funcs, nil
+	funcs, err := cu.getFuncs()
+	if err != nil {
+		return nil, err
+	}
+	idx := newSubprogramIndex(funcs)
+	cu.Binary.subprogramsCMap.Set(k, idx)
+	return idx, nil
 }
 
-func (cu *DWARFCompileUnit) GetSubprogramByAddr(pc uint64) (*DWARFFunction, error) {
-	var sp *DWARFFunction
-	first := true
-	depth := 0
-	r := cu.Dwarf.Reader()
-	r.Seek(cu.Entry.Offset)
-	for {
-		ent, err := r.Next()
-		if err != nil {
-			return nil, err
-		}
-		if ent == nil {
-			break
+// newSubprogramIndex indexes the ranges of the subprograms in funcs. Of
+// overlapping subprograms the first one in DIE order wins.
+func newSubprogramIndex(funcs []*DWARFFunction) *rangeIndex[*DWARFFunction] {
+	var ranges []addrRange[*DWARFFunction]
+	for _, f := range funcs {
+		if f.Type != dwarf.TagSubprogram {
+			continue
 		}
-		if ent.Tag == dwarf.TagCompileUnit {
-			if first {
-				first = false
-				depth++
-				continue
-			}
-			break
-		} else if ent.Tag == dwarf.TagSubprogram {
-			f, err := cu.parseSubprogram(ent, depth)
-			if err != nil {
-				return nil, err
-			}
-			if f == nil {
-				continue
-			}
-			if len(f.Ranges) == 0 {
-				continue
-			}
-			if pc >= f.Ranges[0][0] && pc < f.Ranges[len(f.Ranges)-1][1] {
-				sp = f
-				break
-			}
-		} else if ent.Tag == 0 {
-			depth--
+		for _, r := range f.Ranges {
+			ranges = append(ranges, addrRange[*DWARFFunction]{
+				Start: r[0],
+				End:   r[1],
+				Val:   f,
+			})
 		}
-		if ent.Children {
-			depth++
+	}
+	return newRangeIndex(ranges)
+}
+
+func (f *DWARFFunction) hasPC(pc uint64) bool {
+	for _, r := range f.Ranges {
+		if pc >= r[0] && pc < r[1] {
+			return true
 		}
 	}
-	return sp, nil
+	return false
+}
+
+// extent returns the lowest address of the ranges of f and the end of the
+// highest one.
+func (f *DWARFFunction) extent() (uint64, uint64) {
+	if len(f.Ranges) == 0 {
+		return 0, 0
+	}
+	low, high := f.Ranges[0][0], f.Ranges[0][1]
+	for _, r := range f.Ranges[1:] {
+		low, high = min(low, r[0]), max(high, r[1])
+	}
+	return low, high
 }
 
 func (sp *DWARFFunction) GetSubroutinesBySubprogram() ([]*DWARFFunction, error) {
@@ -290,117 +289,51 @@ func (sp *DWARFFunction) GetSubroutinesBySubprogram() ([]*DWARFFunction, error)
 	return funcs, nil
 }
 
+// getFuncs is like findAllFuncs but caches the functions of cu.
+func (cu *DWARFCompileUnit) getFuncs() ([]*DWARFFunction, error) {
+	k := fmt.Sprintf("%v", cu.Entry.Offset)
+	if e, ok := cu.Binary.subroutinesCMap.Get(k); ok {
+		return e, nil
+	}
+	funcs, err := cu.findAllFuncs()
+	if err != nil {
+		return nil, err
+	}
+	cu.Binary.subroutinesCMap.Set(k, funcs)
+	return funcs, nil
+}
+
 func (cu *DWARFCompileUnit) findAllFuncs() ([]*DWARFFunction, error) {
 	var funcs []*DWARFFunction
-	first := true
-	depth := 0
-	r := cu.Dwarf.Reader()
-	r.Seek(cu.Entry.Offset)
-	for {
-		ent, err := r.Next()
+	for f, err := range cu.Funcs() {
 		if err != nil {
 			return nil, err
 		}
-		if ent == nil {
-			break
-		}
-		if ent.Tag == dwarf.TagCompileUnit {
-			if first {
-				first = false
-				goto loop
-			}
-			break
-		} else if ent.Tag == dwarf.TagSubprogram {
-			f, err := cu.parseSubprogram(ent, depth)
-			if err != nil {
-				return nil, err
-			}
-			if f == nil {
-				goto loop
-			}
-			funcs = append(funcs, f)
-		} else if ent.Tag == dwarf.TagInlinedSubroutine {
-			attrAbstractOrigin := ent.Val(dwarf.AttrAbstractOrigin)
-			if attrAbstractOrigin == nil {
-				goto loop
-			}
-			f, err := cu.parseSubroutine(ent, depth)
-			if err != nil {
-				return nil, err
-			}
-			if f == nil {
-				goto loop
-			}
-			funcs = append(funcs, f)
-		} else if ent.Tag == 0 {
-			depth--
-		}
-	loop:
-		if ent.Children {
-			depth++
-		}
+		funcs = append(funcs, f)
 	}
-	var finalFuncs []*DWARFFunction
-	for _, v := range funcs {
-		if len(v.Ranges) == 0 {
-			continue
-		}
-		finalFuncs = append(finalFuncs, v)
-	}
-	return finalFuncs, nil
+	return funcs, nil
 }
 
 func (cu *DWARFCompileUnit) parseSubprogram(ent *dwarf.Entry, depth int) (*DWARFFunction, error) {
-	attrName := ent.Val(dwarf.AttrName)
-	attrAbstractOrigin := ent.Val(dwarf.AttrAbstractOrigin)
-	var decFile string
-	var decLine int
-	var err error
-
-	if attrAbstractOrigin != nil {
-		ent1, err := cu.getEntryByOffset(attrAbstractOrigin.(dwarf.Offset))
-		if err != nil {
-			return nil, err
-		}
-		attrName = ent1.Val(dwarf.AttrName)
-		decFile, err = cu.getFilenameByIndex(int(ent1.Val(dwarf.AttrDeclFile).(int64)))
-		if err != nil {
-			return nil, err
-		}
-	}
-	if attrName == nil {
-		attrName = ent.Val(dwarf.AttrName)
-	}
-	if attrName == nil {
-		return nil, nil
-	}
-	if decFile == "" && ent.Val(dwarf.AttrDeclFile) != nil {
-		decFile, err = cu.getFilenameByIndex(int(ent.Val(dwarf.AttrDeclFile).(int64)))
-		if err != nil {
-			return nil, err
-		}
-	}
-	attrDecLine := ent.Val(dwarf.AttrDeclLine)
-	if attrDecLine != nil {
-		decLine = int(attrDecLine.(int64))
+	decl, err := cu.declaration(ent)
+	if err != nil || decl.name == "" {
+		return nil, err
 	}
 	ranges, err := cu.Dwarf.Ranges(ent)
 	if err != nil {
 		return nil, err
 	}
-	inline := false
-	attrInline := ent.Val(dwarf.AttrInline)
-	if attrInline != nil {
-		inline = true
-	}
 	f := &DWARFFunction{
 		DwarfCompileUnit: cu,
 		Type:             dwarf.TagSubprogram,
-		Name:             attrName.(string),
+		Name:             decl.name,
+		QualifiedName:    decl.qualifiedName,
+		LinkageName:      decl.linkageName,
 		Ranges:           ranges,
-		DeclFile:         decFile,
-		DeclLine:         decLine,
-		Inline:           inline,
+		EntryPC:          entryPC(ent, ranges),
+		DeclFile:         decl.file,
+		DeclLine:         decl.line,
+		Inline:           ent.Val(dwarf.AttrInline) != nil,
 		Offset:           ent.Offset,
 		Depth:            depth,
 	}
@@ -409,50 +342,15 @@ func (cu *DWARFCompileUnit) parseSubprogram(ent *dwarf.Entry, depth int) (*DWARF
 }
 
 func (cu *DWARFCompileUnit) parseSubroutine(ent *dwarf.Entry, depth int) (*DWARFFunction, error) {
-	attrName := ent.Val(dwarf.AttrName)
-	attrAbstractOrigin := ent.Val(dwarf.AttrAbstractOrigin)
-	var decFile string
-	var decLine int
-	var err error
-
-	callFile, err := cu.getFilenameByIndex(int(ent.Val(dwarf.AttrCallFile).(int64)))
+	callFile, err := cu.fileAttr(ent, dwarf.AttrCallFile)
 	if err != nil {
 		return nil, err
 	}
-	callLine := int(ent.Val(dwarf.AttrCallLine).(int64))
-	var callColumn int
-	cc, ok := ent.Val(dwarf.AttrCallColumn).(int64)
-	if !ok {
-		callColumn = 0
-	} else {
-		callColumn = int(cc)
-	}
-	if attrAbstractOrigin != nil {
-		ent1, err := cu.getEntryByOffset(attrAbstractOrigin.(dwarf.Offset))
-		if err != nil {
-			return nil, err
-		}
-		attrName = ent1.Val(dwarf.AttrName)
-		decFile, err = cu.getFilenameByIndex(int(ent1.Val(dwarf.AttrDeclFile).(int64)))
-		if err != nil {
-			return nil, err
-		}
-	}
-	if attrName == nil {
-		attrName = ent.Val(dwarf.AttrName)
-	}
-	if attrName == nil {
-		return nil, nil
-	}
-	if decFile == "" && ent.Val(dwarf.AttrDeclFile) != nil {
-		decFile, err = cu.getFilenameByIndex(int(ent.Val(dwarf.AttrDeclFile).(int64)))
-		if err != nil {
-			return nil, err
-		}
-	}
-	attrDecLine := ent.Val(dwarf.AttrDeclLine)
-	if attrDecLine != nil {
-		decLine = int(attrDecLine.(int64))
+	callLine, _ := cu.Binary.intAttr(ent, dwarf.AttrCallLine)
+	callColumn, _ := cu.Binary.intAttr(ent, dwarf.AttrCallColumn)
+	decl, err := cu.declaration(ent)
+	if err != nil || decl.name == "" {
+		return nil, err
 	}
 	ranges, err := cu.Dwarf.Ranges(ent)
 	if err != nil {
@@ -461,10 +359,13 @@ func (cu *DWARFCompileUnit) parseSubroutine(ent *dwarf.Entry, depth int) (*DWARF
 	f := &DWARFFunction{
 		DwarfCompileUnit: cu,
 		Type:             dwarf.TagInlinedSubroutine,
-		Name:             attrName.(string),
+		Name:             decl.name,
+		QualifiedName:    decl.qualifiedName,
+		LinkageName:      decl.linkageName,
 		Ranges:           ranges,
-		DeclFile:         decFile,
-		DeclLine:         decLine,
+		EntryPC:          entryPC(ent, ranges),
+		DeclFile:         decl.file,
+		DeclLine:         decl.line,
 		CallFile:         callFile,
 		CallLine:         callLine,
 		CallColumn:       callColumn,
@@ -476,6 +377,99 @@ func (cu *DWARFCompileUnit) parseSubroutine(ent *dwarf.Entry, depth int) (*DWARF
 	return f, nil
 }
 
+// funcDecl is the declaration of a function DIE.
+type funcDecl struct {
+	name          string
+	qualifiedName string
+	linkageName   string
+	file          string
+	line          int
+}
+
+// declaration returns the declaration of ent. Attributes missing on ent
+// are taken from the DIEs named by DW_AT_abstract_origin and
+// DW_AT_specification, which may be in another unit or in the
+// supplementary file. The qualified name is built from the scopes of the
+// last of them, normally the declaration inside a class or namespace.
+func (cu *DWARFCompileUnit) declaration(ent *dwarf.Entry) (funcDecl, error) {
+	var d funcDecl
+	u := cu
+	var err error
+	for range 4 {
+		if d.name == "" {
+			if d.name, err = u.Binary.stringAttr(ent, dwarf.AttrName); err != nil {
+				return funcDecl{}, err
+			}
+		}
+		for _, attr := range []dwarf.Attr{dwarf.AttrLinkageName, attrMIPSLinkageName} {
+			if d.linkageName == "" {
+				if d.linkageName, err = u.Binary.stringAttr(ent, attr); err != nil {
+					return funcDecl{}, err
+				}
+			}
+		}
+		if d.file == "" {
+			if d.file, err = u.fileAttr(ent, dwarf.AttrDeclFile); err != nil {
+				return funcDecl{}, err
+			}
+		}
+		if d.line == 0 {
+			d.line, _ = u.Binary.intAttr(ent, dwarf.AttrDeclLine)
+		}
+		ru, ref, err := u.refEntry(ent, dwarf.AttrAbstractOrigin)
+		if err == nil && ref == nil {
+			ru, ref, err = u.refEntry(ent, dwarf.AttrSpecification)
+		}
+		if err != nil {
+			return funcDecl{}, err
+		}
+		if ref == nil {
+			break
+		}
+		u, ent = ru, ref
+	}
+	if d.name != "" {
+		scope, err := u.scopeOf(ent.Offset)
+		if err != nil {
+			return funcDecl{}, err
+		}
+		d.qualifiedName = scope + d.name
+	}
+	return d, nil
+}
+
+// entryPC returns the entry address of the function ent with ranges:
+// DW_AT_entry_pc, which is an offset from the low PC if it is a constant,
+// or else the low PC, or the start of the first range.
+func entryPC(ent *dwarf.Entry, ranges [][2]uint64) uint64 {
+	low, ok := ent.Val(dwarf.AttrLowpc).(uint64)
+	if !ok && len(ranges) != 0 {
+		low = ranges[0][0]
+	}
+	switch v := ent.Val(dwarf.AttrEntrypc).(type) {
+	case uint64:
+		return v
+	case int64:
+		return low + uint64(v)
+	}
+	return low
+}
+
+// intAttr returns the constant value of attr of ent, a DIE of b. An
+// attribute of another class is reported by Diagnostics and ignored.
+func (b *Binary) intAttr(ent *dwarf.Entry, attr dwarf.Attr) (int, bool) {
+	field := ent.AttrField(attr)
+	if field == nil {
+		return 0, false
+	}
+	v, ok := field.Val.(int64)
+	if !ok || v != int64(int(v)) {
+		b.diagnose(ent.Offset, "%v is %v, not a constant", attr, field.Class)
+		return 0, false
+	}
+	return int(v), true
+}
+
 func (cu *DWARFCompileUnit) getEntryByOffset(offset dwarf.Offset) (*dwarf.Entry, error) {
 	r := cu.Dwarf.Reader()
 	r.Seek(offset)
@@ -37,85 +37,168 @@
 package dwarfparser
 
 import (
+	"context"
 	"debug/dwarf"
 	"fmt"
-	"io"
 	"sort"
-
-	cmap "github.com/orcaman/concurrent-map/v2"
-)
-
-var (
-	lineFilesCMap   = cmap.New[[]*dwarf.LineFile]()
-	lineEntriesCMap = cmap.New[map[uint64]*dwarf.LineEntry]()
 )
 
 func GetLineEntryByAddr(path string, pc uint64) (*dwarf.LineEntry, error) {
-	cu, err := GetCompileUnitByAddr(path, pc)
+	b, release, err := openCached(path)
 	if err != nil {
 		return nil, err
 	}
+	defer release()
+	return b.GetLineEntryByAddr(pc)
+}
+
+func (b *Binary) GetLineEntryByAddr(pc uint64) (*dwarf.LineEntry, error) {
+	le, _, err := b.lineEntryByAddr(pc)
+	return le, err
+}
+
+// lineEntryByAddr also reports whether the row found is at pc or is the
+// nearest lower one.
+func (b *Binary) lineEntryByAddr(pc uint64) (*dwarf.LineEntry, bool, error) {
+	cu, err := b.GetCompileUnitByAddr(pc)
+	if err != nil {
+		return nil, false, err
+	}
 	if cu == nil {
-		return nil, fmt.Errorf("not found Compile Unit for 0x%x", pc)
+		return nil, false, &LookupError{PC: pc, Err: ErrNoCompileUnit}
 	}
+	return cu.lineEntryByAddr(pc)
+}
+
+func (cu *DWARFCompileUnit) lineEntryByAddr(pc uint64) (*dwarf.LineEntry, bool, error) {
 	// TODO: don't use r.SeekPC(pc, ent) which is wrong in golang.
 	// SeekPC assumes address in .debug_line is sorted from low to high pc,
 	// but it's not true.
-	les, err := cu.getLineEntries()
+	lt, err := cu.getLineTable()
 	if err != nil {
-		return nil, err
+		return nil, false, err
 	}
-	if ent, ok := les[pc]; ok {
-		return ent, nil
+	i := lt.find(pc)
+	if i < 0 {
+		return nil, false, &LookupError{PC: pc, Err: ErrNoLineEntry}
 	}
-	// Find nearest LineEntry in case no such LineEntry in .debug_line
-	var entries []*dwarf.LineEntry
-	for _, ent := range les {
-		entries = append(entries, ent)
+	return lt.Rows[i], lt.Rows[i].Address == pc, nil
+}
+
+// lineTable is the line table of a compile unit with its sequences sorted
+// by address. Rows holds the rows of all sequences in that order, without
+// the end_sequence rows.
+type lineTable struct {
+	Rows []*dwarf.LineEntry
+	Seqs []lineSeq
+	// maxEnd[i] is the highest End of Seqs[:i+1].
+	maxEnd []uint64
+}
+
+// lineSeq covers [Start, End) with rows First..Last-1 of its table.
+type lineSeq struct {
+	Start uint64
+	End   uint64
+	First int
+	Last  int
+}
+
+func newLineSeqIndex(seqs []lineSeq) []uint64 {
+	maxEnd := make([]uint64, len(seqs))
+	for i, seq := range seqs {
+		maxEnd[i] = seq.End
+		if i > 0 && maxEnd[i-1] > seq.End {
+			maxEnd[i] = maxEnd[i-1]
+		}
 	}
-	sort.Slice(entries, func(i, j int) bool {
-		return entries[i].Address < entries[j].Address
-	})
-	n := sort.Search(len(entries), func(i int) bool {
-		return entries[i].Address > pc
+	return maxEnd
+}
+
+// findLineRow returns the position of the last row at or below pc within the
+// sequence containing pc, or -1 if pc falls into a gap between sequences.
+// If sequences overlap, the one starting last wins.
+func findLineRow(seqs []lineSeq, maxEnd []uint64, addr func(i int) uint64, pc uint64) int {
+	i := sort.Search(len(seqs), func(i int) bool {
+		return seqs[i].Start > pc
 	})
-	if n-1 >= 0 && n-1 < len(entries) {
-		return entries[n-1], nil
+	for i--; i >= 0 && maxEnd[i] > pc; i-- {
+		seq := seqs[i]
+		if pc >= seq.End {
+			continue
+		}
+		n := sort.Search(seq.Last-seq.First, func(j int) bool {
+			return addr(seq.First+j) > pc
+		})
+		return seq.First + n - 1
 	}
-	return nil, fmt.Errorf("not found 0x%x in .debug_line", pc)
+	return -1
+}
+
+func (lt *lineTable) find(pc uint64) int {
+	return findLineRow(lt.Seqs, lt.maxEnd, func(i int) uint64 {
+		return lt.Rows[i].Address
+	}, pc)
 }
 
 func GenLineFiles(path string) error {
-	cus, err := FindAllCompileUnits(path)
+	return GenLineFilesContext(context.Background(), path)
+}
+
+func GenLineFilesContext(ctx context.Context, path string) error {
+	b, release, err := openCached(path)
 	if err != nil {
 		return err
 	}
-	for _, cu := range cus {
-		_, err := cu.getLineFiles()
-		if err != nil {
-			return err
-		}
+	defer release()
+	return b.GenLineFilesContext(ctx)
+}
+
+func (b *Binary) GenLineFiles() error {
+	return b.GenLineFilesContext(context.Background())
+}
+
+func (b *Binary) GenLineFilesContext(ctx context.Context) error {
+	cus, err := b.FindAllCompileUnits()
+	if err != nil {
+		return err
 	}
-	return nil
+	return b.forEachCompileUnit(ctx, cus, func(i int, cu *DWARFCompileUnit) error {
+		_, err := cu.getLineFiles()
+		return err
+	})
 }
 
 func GenLineEntries(path string) error {
-	cus, err := FindAllCompileUnits(path)
+	return GenLineEntriesContext(context.Background(), path)
+}
+
+func GenLineEntriesContext(ctx context.Context, path string) error {
+	b, release, err := openCached(path)
 	if err != nil {
 		return err
 	}
-	for _, cu := range cus {
-		_, err := cu.getLineEntries()
-		if err != nil {
-			return err
-		}
+	defer release()
+	return b.GenLineEntriesContext(ctx)
+}
+
+func (b *Binary) GenLineEntries() error {
+	return b.GenLineEntriesContext(context.Background())
+}
+
+func (b *Binary) GenLineEntriesContext(ctx context.Context) error {
+	cus, err := b.FindAllCompileUnits()
+	if err != nil {
+		return err
 	}
-	return nil
+	return b.forEachCompileUnit(ctx, cus, func(i int, cu *DWARFCompileUnit) error {
+		_, err := cu.getLineTable()
+		return err
+	})
 }
 
 func (cu *DWARFCompileUnit) getLineFiles() ([]*dwarf.LineFile, error) {
-	k := fmt.Sprintf("%v-%v", cu.FilePath, cu.Entry.Offset)
-	if e, ok := lineFilesCMap.Get(k); ok {
+	k := fmt.Sprintf("%v", cu.Entry.Offset)
+	if e, ok := cu.Binary.lineFilesCMap.Get(k); ok {
 		return e, nil
 	}
 	r, err := cu.Dwarf.LineReader(cu.Entry)
@@ -126,47 +209,86 @@ func (cu *DWARFCompileUnit) getLineFiles() ([]*dwarf.LineFile, error) {
 		return nil, fmt.Errorf("failed to get line reader for %v 0x%x", cu.FilePath, cu.Entry.Offset)
 	}
 	files := r.Files()
-	lineFilesCMap.Set(k, files)
+	cu.Binary.lineFilesCMap.Set(k, files)
 	return files, nil
 }
 
-func (cu *DWARFCompileUnit) getLineEntries() (map[uint64]*dwarf.LineEntry, error) {
-	k := fmt.Sprintf("%v-%v", cu.FilePath, cu.Entry.Offset)
-	if e, ok := lineEntriesCMap.Get(k); ok {
+func (cu *DWARFCompileUnit) getLineTable() (*lineTable, error) {
+	k := fmt.Sprintf("%v", cu.Entry.Offset)
+	if e, ok := cu.Binary.lineTablesCMap.Get(k); ok {
 		return e, nil
 	}
-	lineEntries := make(map[uint64]*dwarf.LineEntry)
-	r, err := cu.Dwarf.LineReader(cu.Entry)
-	if err != nil {
-		return nil, err
-	}
-	if r == nil {
-		return nil, fmt.Errorf("failed to get line reader for %v 0x%x", cu.FilePath, cu.Entry.Offset)
+	type sequence struct {
+		rows []*dwarf.LineEntry
+		end  uint64
 	}
-	for {
-		ent := &dwarf.LineEntry{}
-		if r.Next(ent) == io.EOF {
-			break
+	var seqs []sequence
+	var cur []*dwarf.LineEntry
+	for ent, err := range cu.LineRows() {
+		if err != nil {
+			return nil, err
+		}
+		if !ent.EndSequence {
+			cur = append(cur, ent)
+			continue
 		}
-		lineEntries[ent.Address] = ent
+		if len(cur) != 0 && ent.Address > cur[0].Address {
+			seqs = append(seqs, sequence{
+				rows: cur,
+				end:  ent.Address,
+			})
+		}
+		cur = nil
+	}
+	if len(cur) != 0 {
+		// A sequence without end_sequence row covers up to its last row.
+		seqs = append(seqs, sequence{
+			rows: cur,
+			end:  cur[len(cur)-1].Address + 1,
+		})
 	}
-	lineEntriesCMap.Set(k, lineEntries)
-	return lineEntries, nil
+	sort.SliceStable(seqs, func(i, j int) bool {
+		return seqs[i].rows[0].Address < seqs[j].rows[0].Address
+	})
+	lt := &lineTable{}
+	for _, seq := range seqs {
+		lt.Seqs = append(lt.Seqs, lineSeq{
+			Start: seq.rows[0].Address,
+			End:   seq.end,
+			First: len(lt.Rows),
+			Last:  len(lt.Rows) + len(seq.rows),
+		})
+		lt.Rows = append(lt.Rows, seq.rows...)
+	}
+	lt.maxEnd = newLineSeqIndex(lt.Seqs)
+	cu.Binary.lineTablesCMap.Set(k, lt)
+	return lt, nil
 }
 
-func (cu *DWARFCompileUnit) getFilenameByIndex(index int) (string, error) {
+// fileAttr returns the resolved name of the file referenced by attr of
+// ent, a DIE of cu, or "" if there is none. A reference out of the file
+// table, or to a unit without one, is reported by Diagnostics and ignored.
+func (cu *DWARFCompileUnit) fileAttr(ent *dwarf.Entry, attr dwarf.Attr) (string, error) {
+	index, ok := cu.Binary.intAttr(ent, attr)
+	if !ok || index == 0 && cu.Entry.Val(dwarf.AttrStmtList) == nil {
+		return "", nil
+	}
+	if cu.Entry.Val(dwarf.AttrStmtList) == nil {
+		cu.Binary.diagnose(ent.Offset, "%v %v in a unit without line table", attr, index)
+		return "", nil
+	}
 	files, err := cu.getLineFiles()
 	if err != nil {
 		return "", err
 	}
-	if files == nil {
-		return "", fmt.Errorf("files == nil")
-	}
-	if index >= len(files) {
-		return "", fmt.Errorf("index (%v) >= len(files) (%v)", index, len(files))
+	// Up to DWARF 4 files are numbered from 1 and 0 means none, DWARF 5
+	// numbers them from 0.
+	if index == 0 && (len(files) == 0 || files[0] == nil) {
+		return "", nil
 	}
-	if index == 0 {
+	if index < 0 || index >= len(files) || files[index] == nil {
+		cu.Binary.diagnose(ent.Offset, "%v %v out of %v files", attr, index, len(files))
 		return "", nil
 	}
-	return files[index].Name, nil
+	return cu.resolvePath(files[index].Name), nil
 }
@@ -0,0 +1,126 @@
+// =============================================================================
+//  @@-COPYRIGHT-START-@@
+//
+//  Copyright (c) 2024, Qualcomm Innovation Center, Inc. All rights reserved.
+//
+//  Redistribution and use in source and binary forms, with or without
+//  modification, are permitted provided that the following conditions are met:
+//
+//  1. Redistributions of source code must retain the above copyright notice,
+//     this list of conditions and the following disclaimer.
+//
+//  2. Redistributions in binary form must reproduce the above copyright notice,
+//     this list of conditions and the following disclaimer in the documentation
+//     and/or other materials provided with the distribution.
+//
+//  3. Neither the name of the copyright holder nor the names of its contributors
+//     may be used to endorse or promote products derived from this software
+//     without specific prior written permission.
+//
+//  THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS"
+//  AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE
+//  IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE
+//  ARE DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT HOLDER OR CONTRIBUTORS BE
+//  LIABLE FOR ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR
+//  CONSEQUENTIAL DAMAGES (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF
+//  SUBSTITUTE GOODS OR SERVICES; LOSS OF USE, DATA, OR PROFITS; OR BUSINESS
+//  INTERRUPTION) HOWEVER CAUSED AND ON ANY THEORY OF LIABILITY, WHETHER IN
+//  CONTRACT, STRICT LIABILITY, OR TORT (INCLUDING NEGLIGENCE OR OTHERWISE)
+//  ARISING IN ANY WAY OUT OF THE USE OF THIS SOFTWARE, EVEN IF ADVISED OF THE
+//  POSSIBILITY OF SUCH DAMAGE.
+//
+//  SPDX-License-Identifier: BSD-3-Clause
+//
+//  @@-COPYRIGHT-END-@@
+// =============================================================================
+
+package dwarfparser
+
+import (
+	"errors"
+	"path/filepath"
+	"testing"
+)
+
+// The line table of testdata/cold has a sequence for fail and the cold part
+// of work at 0x401000-0x401007 and one for the rest of work at
+// 0x401010-0x40101c.
+func TestLineEntryByAddr(t *testing.T) {
+	b, err := Open(filepath.Join("testdata", "cold"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer b.Close()
+	var cu *DWARFCompileUnit
+	for c, err := range b.CompileUnits() {
+		if err != nil {
+			t.Fatal(err)
+		}
+		cu = c
+		break
+	}
+	for _, tt := range []struct {
+		pc     uint64
+		line   int
+		column int
+		exact  bool
+	}{
+		{0x401000, 1, 52, true},
+		{0x401006, 6, 3, false},
+		{0x401007, 0, 0, false},
+		{0x40100f, 0, 0, false},
+		{0x401010, 5, 5, true},
+		{0x40101b, 10, 1, true},
+		{0x40101c, 0, 0, false},
+		{0x401020, 0, 0, false},
+	} {
+		le, exact, err := cu.lineEntryByAddr(tt.pc)
+		if tt.line == 0 {
+			if !errors.Is(err, ErrNoLineEntry) {
+				t.Errorf("lineEntryByAddr(0x%x) = %v, %v, want %v", tt.pc, le, err, ErrNoLineEntry)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("lineEntryByAddr(0x%x): %v", tt.pc, err)
+			continue
+		}
+		if le.Line != tt.line || le.Column != tt.column || exact != tt.exact {
+			t.Errorf("lineEntryByAddr(0x%x) = %v:%v, %v, want %v:%v, %v",
+				tt.pc, le.Line, le.Column, exact, tt.line, tt.column, tt.exact)
+		}
+	}
+}
+
+func TestFindLineRow(t *testing.T) {
+	// Rows 0-2 are a sequence at 0x100-0x130, rows 3-4 one at 0x200-0x220
+	// and rows 5-6 one at 0x110-0x118 overlapping the first.
+	seqs := []lineSeq{
+		{Start: 0x100, End: 0x130, First: 0, Last: 3},
+		{Start: 0x110, End: 0x118, First: 5, Last: 7},
+		{Start: 0x200, End: 0x220, First: 3, Last: 5},
+	}
+	addrs := []uint64{0x100, 0x110, 0x120, 0x200, 0x210, 0x110, 0x114}
+	addr := func(i int) uint64 { return addrs[i] }
+	for _, tt := range []struct {
+		pc   uint64
+		want int
+	}{
+		{0xff, -1},
+		{0x100, 0},
+		{0x10f, 0},
+		{0x110, 5},
+		{0x117, 6},
+		{0x118, 1},
+		{0x12f, 2},
+		{0x130, -1},
+		{0x1ff, -1},
+		{0x200, 3},
+		{0x21f, 4},
+		{0x220, -1},
+	} {
+		if got := findLineRow(seqs, newLineSeqIndex(seqs), addr, tt.pc); got != tt.want {
+			t.Errorf("findLineRow(0x%x) = %v, want %v", tt.pc, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,253 @@
+// =============================================================================
+//  @@-COPYRIGHT-START-@@
+//
+//  Copyright (c) 2024, Qualcomm Innovation Center, Inc. All rights reserved.
+//
+//  Redistribution and use in source and binary forms, with or without
+//  modification, are permitted provided that the following conditions are met:
+//
+//  1. Redistributions of source code must retain the above copyright notice,
+//     this list of conditions and the following disclaimer.
+//
+//  2. Redistributions in binary form must reproduce the above copyright notice,
+//     this list of conditions and the following disclaimer in the documentation
+//     and/or other materials provided with the distribution.
+//
+//  3. Neither the name of the copyright holder nor the names of its contributors
+//     may be used to endorse or promote products derived from this software
+//     without specific prior written permission.
+//
+//  THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS"
+//  AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE
+//  IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE
+//  ARE DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT HOLDER OR CONTRIBUTORS BE
+//  LIABLE FOR ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR
+//  CONSEQUENTIAL DAMAGES (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF
+//  SUBSTITUTE GOODS OR SERVICES; LOSS OF USE, DATA, OR PROFITS; OR BUSINESS
+//  INTERRUPTION) HOWEVER CAUSED AND ON ANY THEORY OF LIABILITY, WHETHER IN
+//  CONTRACT, STRICT LIABILITY, OR TORT (INCLUDING NEGLIGENCE OR OTHERWISE)
+//  ARISING IN ANY WAY OUT OF THE USE OF THIS SOFTWARE, EVEN IF ADVISED OF THE
+//  POSSIBILITY OF SUCH DAMAGE.
+//
+//  SPDX-License-Identifier: BSD-3-Clause
+//
+//  @@-COPYRIGHT-END-@@
+// =============================================================================
+
+package dwarfparser
+
+import (
+	"bytes"
+	"debug/elf"
+	"hash/crc32"
+	"io"
+	"os"
+	"path/filepath"
+	"slices"
+	"sync"
+)
+
+var debugDirs = struct {
+	sync.Mutex
+	dirs []string
+}{dirs: defaultDebugDirs}
+
+var defaultDebugDirs = []string{"/usr/lib/debug"}
+
+// SetDebugDirs sets the debug directories of the Binaries used by the path
+// based functions of this package, see Binary.SetDebugDirs. Cached Binaries
+// are dropped so that they are searched again.
+func SetDebugDirs(dirs ...string) {
+	debugDirs.Lock()
+	debugDirs.dirs = slices.Clone(dirs)
+	debugDirs.Unlock()
+	PurgeAll()
+}
+
+// DebugDirs returns the directories set by SetDebugDirs.
+func DebugDirs() []string {
+	debugDirs.Lock()
+	defer debugDirs.Unlock()
+	return slices.Clone(debugDirs.dirs)
+}
+
+// SetDebugDirs sets the directories searched for the separate debug file of
+// b, "/usr/lib/debug" by default. With no dirs, only the .gnu_debuglink
+// paths next to b are tried. It has no effect once b was searched.
+func (b *Binary) SetDebugDirs(dirs ...string) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.debugDirs = append([]string{}, dirs...)
+}
+
+// DebugDirs returns the directories searched for the separate debug file of
+// b.
+func (b *Binary) DebugDirs() []string {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.debugDirs == nil {
+		return slices.Clone(defaultDebugDirs)
+	}
+	return slices.Clone(b.debugDirs)
+}
+
+// DebugFile returns the path of the separate debug file of the binary at
+// path, see Binary.DebugFile.
+func DebugFile(path string) (string, error) {
+	b, release, err := openCached(path)
+	if err != nil {
+		return "", err
+	}
+	defer release()
+	return b.DebugFile()
+}
+
+// DebugFile returns the path of the separate debug file the DWARF of b is
+// read from, or "" if b has its own or none was found.
+func (b *Binary) DebugFile() (string, error) {
+	db, err := b.debugBinary()
+	if err != nil || db == b {
+		return "", err
+	}
+	return db.Path, nil
+}
+
+// debugFile is like File but returns the ELF file holding the DWARF of b,
+// together with the Binary to read its sections with.
+func (b *Binary) debugFile() (*Binary, *elf.File, error) {
+	db, err := b.debugBinary()
+	if err != nil {
+		return nil, nil, err
+	}
+	f, err := db.File()
+	return db, f, err
+}
+
+// debugBinary returns b if it has debug info, or else its separate debug
+// file if one is found. It is opened once and closed together with b.
+func (b *Binary) debugBinary() (*Binary, error) {
+	b.mu.Lock()
+	if b.debug != nil {
+		defer b.mu.Unlock()
+		return b.debug, nil
+	}
+	b.mu.Unlock()
+	f, err := b.File()
+	if err != nil {
+		return nil, err
+	}
+	db := b
+	if !hasDebugInfo(f) {
+		if db = b.findDebugFile(f); db == nil {
+			db = b
+		}
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.debug != nil {
+		if db != b {
+			db.Close()
+		}
+		return b.debug, nil
+	}
+	b.debug = db
+	return db, nil
+}
+
+func hasDebugInfo(f *elf.File) bool {
+	return debugSection(f, ".debug_info") != nil
+}
+
+// findDebugFile looks for the debug file of b the way GDB does: by build-id
+// in the .build-id directory of each debug directory, then by the name in
+// .gnu_debuglink next to b, in its .debug subdirectory and under each debug
+// directory. The build-id or CRC of a candidate must match.
+func (b *Binary) findDebugFile(f *elf.File) *Binary {
+	dirs := b.DebugDirs()
+	if id, err := b.BuildID(); err == nil && len(id) > 2 {
+		for _, dir := range dirs {
+			path := filepath.Join(dir, ".build-id", id[:2], id[2:]+".debug")
+			db := b.openDebugFile(path, func(db *Binary) bool {
+				dbID, err := db.BuildID()
+				return err == nil && dbID == id
+			})
+			if db != nil {
+				return db
+			}
+		}
+	}
+	link, crc, ok := b.debugLink(f)
+	if !ok {
+		return nil
+	}
+	dir := filepath.Dir(b.Path)
+	paths := []string{filepath.Join(dir, link), filepath.Join(dir, ".debug", link)}
+	if abs, err := filepath.Abs(dir); err == nil {
+		for _, d := range dirs {
+			paths = append(paths, filepath.Join(d, abs, link))
+		}
+	}
+	for _, path := range paths {
+		db := b.openDebugFile(path, func(db *Binary) bool {
+			sum, err := fileCRC(path)
+			return err == nil && sum == crc
+		})
+		if db != nil {
+			return db
+		}
+	}
+	return nil
+}
+
+// openDebugFile opens path if it is a file other than b with debug info
+// and verified by match.
+func (b *Binary) openDebugFile(path string, match func(db *Binary) bool) *Binary {
+	info, err := os.Stat(path)
+	if err != nil || !info.Mode().IsRegular() {
+		return nil
+	}
+	if self, err := os.Stat(b.Path); err == nil && os.SameFile(info, self) {
+		return nil
+	}
+	db, err := b.openRelated(path)
+	if err != nil {
+		return nil
+	}
+	if f, err := db.File(); err != nil || !hasDebugInfo(f) || !match(db) {
+		db.Close()
+		return nil
+	}
+	return db
+}
+
+// debugLink returns the file name and CRC held by .gnu_debuglink.
+func (b *Binary) debugLink(f *elf.File) (string, uint32, bool) {
+	s := f.Section(".gnu_debuglink")
+	if s == nil {
+		return "", 0, false
+	}
+	data, err := b.sectionData(s)
+	if err != nil {
+		return "", 0, false
+	}
+	i := bytes.IndexByte(data, 0)
+	// The name is padded to 4 bytes and followed by the CRC.
+	off := (i + 4) &^ 3
+	if i <= 0 || off+4 > len(data) {
+		return "", 0, false
+	}
+	return string(data[:i]), f.ByteOrder.Uint32(data[off:]), true
+}
+
+func fileCRC(path string) (uint32, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return 0, err
+	}
+	defer file.Close()
+	h := crc32.NewIEEE()
+	if _, err := io.Copy(h, file); err != nil {
+		return 0, err
+	}
+	return h.Sum32(), nil
+}
@@ -0,0 +1,135 @@
+// =============================================================================
+//  @@-COPYRIGHT-START-@@
+//
+//  Copyright (c) 2024, Qualcomm Innovation Center, Inc. All rights reserved.
+//
+//  Redistribution and use in source and binary forms, with or without
+//  modification, are permitted provided that the following conditions are met:
+//
+//  1. Redistributions of source code must retain the above copyright notice,
+//     this list of conditions and the following disclaimer.
+//
+//  2. Redistributions in binary form must reproduce the above copyright notice,
+//     this list of conditions and the following disclaimer in the documentation
+//     and/or other materials provided with the distribution.
+//
+//  3. Neither the name of the copyright holder nor the names of its contributors
+//     may be used to endorse or promote products derived from this software
+//     without specific prior written permission.
+//
+//  THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS"
+//  AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE
+//  IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE
+//  ARE DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT HOLDER OR CONTRIBUTORS BE
+//  LIABLE FOR ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR
+//  CONSEQUENTIAL DAMAGES (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF
+//  SUBSTITUTE GOODS OR SERVICES; LOSS OF USE, DATA, OR PROFITS; OR BUSINESS
+//  INTERRUPTION) HOWEVER CAUSED AND ON ANY THEORY OF LIABILITY, WHETHER IN
+//  CONTRACT, STRICT LIABILITY, OR TORT (INCLUDING NEGLIGENCE OR OTHERWISE)
+//  ARISING IN ANY WAY OUT OF THE USE OF THIS SOFTWARE, EVEN IF ADVISED OF THE
+//  POSSIBILITY OF SUCH DAMAGE.
+//
+//  SPDX-License-Identifier: BSD-3-Clause
+//
+//  @@-COPYRIGHT-END-@@
+// =============================================================================
+
+package dwarfparser
+
+import (
+	"bytes"
+	"encoding/hex"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// testdata/inl.stripped is testdata/inl without debug sections and with a
+// .gnu_debuglink to inl.debug, which holds them. Both have the build-id of
+// inl.
+func TestDebugFile(t *testing.T) {
+	const buildID = "5fa742918e23929187877daa088eb8e046ff6e74"
+	debug, err := os.ReadFile(filepath.Join("testdata", "inl.debug"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	// A file with another CRC, and one with another build-id as well.
+	otherCRC := append(bytes.Clone(debug), 0)
+	id, _ := hex.DecodeString(buildID)
+	otherID := bytes.Clone(debug)
+	i := bytes.Index(otherID, id)
+	otherID[i]++
+	buildIDPath := filepath.Join("{debug}", ".build-id", buildID[:2], buildID[2:]+".debug")
+	// Paths are relative to a temporary directory, with the stripped binary
+	// in bin. {debug} is the debug directory and {abs} the absolute path of
+	// bin.
+	for _, tt := range []struct {
+		name  string
+		files map[string][]byte
+		dirs  bool
+		want  string
+	}{
+		{"next to the binary", map[string][]byte{"bin/inl.debug": debug}, true, "bin/inl.debug"},
+		{".debug", map[string][]byte{"bin/.debug/inl.debug": debug}, true, "bin/.debug/inl.debug"},
+		{"debug directory", map[string][]byte{"{debug}/{abs}/inl.debug": debug}, true, "{debug}/{abs}/inl.debug"},
+		{"build-id", map[string][]byte{buildIDPath: debug}, true, buildIDPath},
+		{"build-id first", map[string][]byte{buildIDPath: debug, "bin/inl.debug": debug}, true, buildIDPath},
+		{"other CRC", map[string][]byte{"bin/inl.debug": otherCRC}, true, ""},
+		{"build-id without CRC", map[string][]byte{buildIDPath: otherCRC}, true, buildIDPath},
+		{"other build-id", map[string][]byte{buildIDPath: otherID}, true, ""},
+		{"no debug directories", map[string][]byte{buildIDPath: debug}, false, ""},
+		{"no debug directories next to the binary", map[string][]byte{"bin/inl.debug": debug}, false, "bin/inl.debug"},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			root := t.TempDir()
+			path := filepath.Join(root, "bin", "inl.stripped")
+			if err := os.Mkdir(filepath.Dir(path), 0755); err != nil {
+				t.Fatal(err)
+			}
+			expand := func(name string) string {
+				name = strings.ReplaceAll(name, "{debug}", "debug")
+				name = strings.ReplaceAll(name, "{abs}", filepath.Dir(path))
+				return filepath.Join(root, name)
+			}
+			for name, data := range tt.files {
+				name = expand(name)
+				if err := os.MkdirAll(filepath.Dir(name), 0755); err != nil {
+					t.Fatal(err)
+				}
+				if err := os.WriteFile(name, data, 0644); err != nil {
+					t.Fatal(err)
+				}
+			}
+			copyFile(t, path, filepath.Join("testdata", "inl.stripped"))
+			b, err := Open(path)
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer b.Close()
+			if tt.dirs {
+				b.SetDebugDirs(expand("{debug}"))
+			} else {
+				b.SetDebugDirs()
+			}
+			got, err := b.DebugFile()
+			if err != nil {
+				t.Fatal(err)
+			}
+			want := ""
+			if tt.want != "" {
+				want = expand(tt.want)
+			}
+			if got != want {
+				t.Fatalf("DebugFile() = %q, want %q", got, want)
+			}
+			if want == "" {
+				return
+			}
+			frames, err := b.Addr2line(0x401006)
+			if err != nil || frames[0].Func != "twice" {
+				t.Errorf("Addr2line(0x401006) = %v, %v, want twice", frames, err)
+			}
+		})
+	}
+}
@@ -37,18 +37,25 @@
 package dwarfparser
 
 import (
-	"debug/elf"
 	"encoding/binary"
 	"fmt"
 	"io"
 )
 
 func DumpSection(path, sec string) error {
-	f, err := elf.Open(path)
+	b, release, err := openCached(path)
+	if err != nil {
+		return err
+	}
+	defer release()
+	return b.DumpSection(sec)
+}
+
+func (b *Binary) DumpSection(sec string) error {
+	f, err := b.File()
 	if err != nil {
 		return err
 	}
-	defer f.Close()
 	s, err := GetSectionByName(f, sec)
 	if err != nil {
 		return err
@@ -37,47 +37,198 @@
 package dwarfparser
 
 import (
+	"context"
 	"debug/dwarf"
 	"debug/elf"
 	"fmt"
 	"sort"
-
-	cmap "github.com/orcaman/concurrent-map/v2"
-)
-
-var (
-	dwarfCMap = cmap.New[*dwarf.Data]()
+	"strings"
 )
 
 func DWARF(path string) (*dwarf.Data, error) {
-	if e, ok := dwarfCMap.Get(path); ok {
-		return e, nil
+	b, release, err := openCached(path)
+	if err != nil {
+		return nil, err
+	}
+	defer release()
+	return b.DWARF()
+}
+
+func (b *Binary) DWARF() (*dwarf.Data, error) {
+	b.mu.Lock()
+	di := b.dwarf
+	b.mu.Unlock()
+	if di != nil {
+		// Set up front for split units, which may have no file.
+		return di, nil
 	}
-	file, err := elf.Open(path)
+	f, err := b.File()
 	if err != nil {
 		return nil, err
 	}
-	defer file.Close()
-	di, err := file.DWARF()
+	if !hasDebugInfo(f) {
+		db, err := b.debugBinary()
+		if err != nil {
+			return nil, err
+		}
+		if db == b {
+			return nil, fmt.Errorf("%v: %w", b.Path, ErrNoDebugInfo)
+		}
+		return db.DWARF()
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.dwarf != nil {
+		return b.dwarf, nil
+	}
+	if canRelocate(f) || (b.mapping != nil && !hasDebugRelocations(f)) {
+		di, err = b.loadDWARF(f)
+	} else {
+		di, err = f.DWARF()
+	}
 	if err != nil {
 		return nil, err
 	}
 	if di == nil {
-		return nil, fmt.Errorf("not found .debug_info section")
+		return nil, fmt.Errorf("%v: %w", b.Path, ErrNoDebugInfo)
 	}
-	dwarfCMap.Set(path, di)
+	b.dwarf = di
 	return di, nil
 }
 
+func dwarfSuffix(s *elf.Section) string {
+	switch {
+	case strings.HasPrefix(s.Name, ".debug_"):
+		return s.Name[7:]
+	case strings.HasPrefix(s.Name, ".zdebug_"):
+		return s.Name[8:]
+	default:
+		return ""
+	}
+}
+
+func hasDebugRelocations(f *elf.File) bool {
+	if f.Type == elf.ET_EXEC {
+		return false
+	}
+	for _, r := range f.Sections {
+		if r.Type != elf.SHT_RELA && r.Type != elf.SHT_REL {
+			continue
+		}
+		if int(r.Info) < len(f.Sections) && dwarfSuffix(f.Sections[r.Info]) != "" {
+			return true
+		}
+	}
+	return false
+}
+
+// loadDWARF is like elf.File.DWARF but reads the sections through
+// b.sectionData. Relocations are applied by relocateSection if canRelocate,
+// and not at all otherwise.
+func (b *Binary) loadDWARF(f *elf.File) (*dwarf.Data, error) {
+	skipped := make(map[[2]string]int)
+	sectionData, err := b.debugSectionReader(f, skipped)
+	if err != nil {
+		return nil, err
+	}
+	var dat = map[string][]byte{"abbrev": nil, "info": nil, "str": nil, "line": nil, "ranges": nil}
+	for i, s := range f.Sections {
+		suffix := dwarfSuffix(s)
+		if _, ok := dat[suffix]; !ok {
+			continue
+		}
+		data, err := sectionData(i, s)
+		if err != nil {
+			return nil, err
+		}
+		dat[suffix] = data
+	}
+	d, err := dwarf.New(dat["abbrev"], nil, nil, dat["info"], dat["line"], nil, dat["ranges"], dat["str"])
+	if err != nil {
+		return nil, err
+	}
+	for i, s := range f.Sections {
+		suffix := dwarfSuffix(s)
+		if suffix == "" {
+			continue
+		}
+		if _, ok := dat[suffix]; ok {
+			continue
+		}
+		data, err := sectionData(i, s)
+		if err != nil {
+			return nil, err
+		}
+		if suffix == "types" {
+			err = d.AddTypes(fmt.Sprintf("types-%d", i), data)
+		} else {
+			err = d.AddSection(".debug_"+suffix, data)
+		}
+		if err != nil {
+			return nil, err
+		}
+	}
+	// b.mu is held by DWARF.
+	b.skippedRelocs = sortSkippedRelocations(skipped)
+	return d, nil
+}
+
+// debugSectionReader returns a function reading the debug section s, the
+// ith section of f, with relocations applied as by loadDWARF. Relocations
+// that could not be applied are counted in skipped.
+func (b *Binary) debugSectionReader(f *elf.File, skipped map[[2]string]int) (func(i int, s *elf.Section) ([]byte, error), error) {
+	var symbols []elf.Symbol
+	var bases []uint64
+	if canRelocate(f) {
+		var err error
+		if b.mapping != nil {
+			symbols, err = b.readSymbols(f)
+		} else {
+			symbols, err = f.Symbols()
+		}
+		if err != nil {
+			return nil, err
+		}
+		bases = sectionBases(f)
+	}
+	return func(i int, s *elf.Section) ([]byte, error) {
+		data, err := b.sectionData(s)
+		if err != nil || bases == nil {
+			return data, err
+		}
+		return b.relocateSection(f, i, data, symbols, bases, skipped)
+	}, nil
+}
+
 func FindAllPCs(path string, filterTracePC bool) ([]uint64, error) {
+	return FindAllPCsContext(context.Background(), path, filterTracePC)
+}
+
+func FindAllPCsContext(ctx context.Context, path string, filterTracePC bool) ([]uint64, error) {
+	b, release, err := openCached(path)
+	if err != nil {
+		return nil, err
+	}
+	defer release()
+	return b.FindAllPCsContext(ctx, filterTracePC)
+}
+
+func (b *Binary) FindAllPCs(filterTracePC bool) ([]uint64, error) {
+	return b.FindAllPCsContext(context.Background(), filterTracePC)
+}
+
+func (b *Binary) FindAllPCsContext(ctx context.Context, filterTracePC bool) ([]uint64, error) {
 	var pcs []uint64
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
 	if filterTracePC {
 		var coverPoints [2][]uint64
 		var err error
-		if IsSectionExist(path, ".rela.text") {
-			coverPoints, err = FindAllCoverPointsInRelaSec(path)
+		if b.IsSectionExist(".rela.text") {
+			coverPoints, err = b.FindAllCoverPointsInRelaSec()
 		} else {
-			coverPoints, err = FindAllCoverPoints(path)
+			coverPoints, err = b.FindAllCoverPoints()
 		}
 		if err != nil {
 			return nil, err
@@ -86,22 +237,31 @@ func FindAllPCs(path string, filterTracePC bool) ([]uint64, error) {
 			pcs = append(pcs, pcs1...)
 		}
 	} else {
-		err := GenLineEntries(path)
+		cus, err := b.FindAllCompileUnits()
 		if err != nil {
 			return nil, err
 		}
-		cus, err := FindAllCompileUnits(path)
+		// One pc per address of each unit with rows at it, end_sequence
+		// rows included.
+		results := make([][]uint64, len(cus))
+		err = b.forEachCompileUnit(ctx, cus, func(i int, cu *DWARFCompileUnit) error {
+			seen := make(map[uint64]bool)
+			for ent, err := range cu.LineRows() {
+				if err != nil {
+					return err
+				}
+				if !seen[ent.Address] {
+					seen[ent.Address] = true
+					results[i] = append(results[i], ent.Address)
+				}
+			}
+			return nil
+		})
 		if err != nil {
 			return nil, err
 		}
-		for _, cu := range cus {
-			lineEntries, err := cu.getLineEntries()
-			if err != nil {
-				return nil, err
-			}
-			for _, ent := range lineEntries {
-				pcs = append(pcs, ent.Address)
-			}
+		for _, pcs1 := range results {
+			pcs = append(pcs, pcs1...)
 		}
 	}
 	sort.Slice(pcs, func(i, j int) bool {
@@ -111,76 +271,150 @@ func FindAllPCs(path string, filterTracePC bool) ([]uint64, error) {
 }
 
 func Addr2line(path string, pc uint64) ([]Frame, error) {
-	frames, err := FindAllFramesByAddr(path, pc)
+	b, release, err := openCached(path)
 	if err != nil {
-		return nil, err
+		return []Frame{unknownFrame(pc)}, err
 	}
-	return frames, nil
+	defer release()
+	return b.Addr2line(pc)
+}
+
+// Addr2line returns the frames of pc, innermost first. On error it still
+// returns the best partial frames, with "??" for what could not be resolved,
+// like GNU addr2line prints.
+func (b *Binary) Addr2line(pc uint64) ([]Frame, error) {
+	return b.FindAllFramesByAddr(pc)
 }
 
 func FindAllFramesByAddr(path string, pc uint64) ([]Frame, error) {
-	var frames []Frame
-	cu, err := GetCompileUnitByAddr(path, pc)
+	b, release, err := openCached(path)
 	if err != nil {
-		return nil, err
+		return []Frame{unknownFrame(pc)}, err
+	}
+	defer release()
+	return b.FindAllFramesByAddr(pc)
+}
+
+func unknownFrame(pc uint64) Frame {
+	return Frame{
+		PC:   pc,
+		Func: "??",
+		File: "??",
+	}
+}
+
+func (b *Binary) FindAllFramesByAddr(pc uint64) ([]Frame, error) {
+	return b.findAllFramesByAddr(pc, nil)
+}
+
+// Explain is like Addr2line but also reports how each frame was chosen.
+func (b *Binary) Explain(pc uint64) ([]Frame, *Explanation, error) {
+	ex := &Explanation{
+		PC:         pc,
+		prefixMaps: b.PrefixMaps(),
+	}
+	frames, err := b.findAllFramesByAddr(pc, ex)
+	return frames, ex, err
+}
+
+func (b *Binary) findAllFramesByAddr(pc uint64, ex *Explanation) ([]Frame, error) {
+	cu, err := b.GetCompileUnitByAddr(pc)
+	if err != nil {
+		return []Frame{unknownFrame(pc)}, err
+	}
+	return b.findFramesInCU(cu, pc, ex)
+}
+
+// findFramesInCU returns the frames of pc in cu with the paths remapped by
+// the rules set by SetPrefixMaps.
+func (b *Binary) findFramesInCU(cu *DWARFCompileUnit, pc uint64, ex *Explanation) ([]Frame, error) {
+	frames, err := b.framesInCU(cu, pc, ex)
+	remapFrames(b.PrefixMaps(), frames)
+	return frames, err
+}
+
+func (b *Binary) framesInCU(cu *DWARFCompileUnit, pc uint64, ex *Explanation) ([]Frame, error) {
+	top := unknownFrame(pc)
+	le, exact, lineErr := cu.lineEntryByAddr(pc)
+	if lineErr == nil {
+		if le.File != nil {
+			top.File = cu.resolvePath(le.File.Name)
+		}
+		top.Line = le.Line
+		top.Column = le.Column
+		top.Discriminator = le.Discriminator
+	}
+	if ex != nil {
+		ex.CompileUnit = cu
+		ex.Line = le
+		ex.LineExact = exact
 	}
 	sp, err := cu.GetSubprogramByAddr(pc)
 	if err != nil {
-		return nil, err
+		return []Frame{top}, err
 	}
-	frames = append(frames, Frame{
-		PC:     uint64(sp.Offset),
-		Func:   sp.Name,
-		File:   sp.DeclFile,
-		Line:   sp.DeclLine,
-		Inline: sp.Inline,
-	})
-	k := fmt.Sprintf("%v-%v-%v", path, cu.Entry.Offset, sp.Offset)
-	rts, ok := allSubroutinesCMap.Get(k)
+	if ex != nil {
+		ex.Subprogram = sp
+	}
+	k := fmt.Sprintf("%v-%v", cu.Entry.Offset, sp.Offset)
+	rts, ok := b.subroutinesCMap.Get(k)
 	if !ok {
 		rts, err = sp.GetSubroutinesBySubprogram()
 		if err != nil {
-			return nil, err
+			top.Func = sp.Name
+			top.QualifiedFunc = sp.QualifiedName
+			top.LinkageName = sp.LinkageName
+			return []Frame{top}, err
 		}
-		allSubroutinesCMap.Set(k, rts)
-	}
-	for i := len(rts); i > 0; i-- {
-		f := rts[i-1]
-		for _, r := range f.Ranges {
-			if pc >= r[0] && pc < r[1] {
-				frames = append(frames, Frame{
-					PC:     uint64(f.Offset),
-					Func:   f.Name,
-					File:   f.CallFile,
-					Line:   f.CallLine,
-					Inline: f.Inline,
-				})
-				break
-			}
+		b.subroutinesCMap.Set(k, rts)
+	}
+	if ex != nil {
+		ex.Inlines = rts
+	}
+	return assembleFrames(pc, top, sp, rts), lineErr
+}
+
+// assembleFrames builds the frames of pc from the subprogram sp containing
+// it and the inlined subroutines rts of sp. top holds the location of pc
+// from .debug_line.
+func assembleFrames(pc uint64, top Frame, sp *DWARFFunction, rts []*DWARFFunction) []Frame {
+	funcs := []*DWARFFunction{sp}
+	for _, f := range rts {
+		if f.hasPC(pc) {
+			funcs = append(funcs, f)
 		}
 	}
-	sort.Slice(frames, func(i int, j int) bool {
-		return frames[i].PC > frames[j].PC
+	// Inlined subroutines are nested in DIE order, the innermost is last.
+	sort.Slice(funcs, func(i int, j int) bool {
+		return funcs[i].Offset > funcs[j].Offset
 	})
-	le, err := GetLineEntryByAddr(path, pc)
-	if err != nil {
-		return nil, err
+	var frames []Frame
+	loc := top
+	for _, f := range funcs {
+		frame := loc
+		frame.Func = f.Name
+		frame.QualifiedFunc = f.QualifiedName
+		frame.LinkageName = f.LinkageName
+		frame.FuncEntry = f.EntryPC
+		if low, high := f.extent(); pc >= low && pc < high {
+			frame.FuncOffset = pc - low
+			frame.FuncSize = high - low
+		}
+		frame.StartFile = f.DeclFile
+		frame.StartLine = f.DeclLine
+		if cu := f.DwarfCompileUnit; cu != nil {
+			frame.CUName = cu.Name
+			frame.CompDir = cu.CompDir
+		}
+		frame.DIEOffset = f.Offset
+		frames = append(frames, frame)
+		loc = Frame{
+			PC:     pc,
+			File:   f.CallFile,
+			Line:   f.CallLine,
+			Column: f.CallColumn,
+			Inline: f.Inline,
+		}
 	}
-	frames = append([]Frame{
-		{
-			PC:   pc,
-			File: le.File.Name,
-			Line: le.Line,
-		},
-	}, frames...)
-	var next Frame
-	for i := 0; i < len(frames)-1; i++ {
-		next = frames[i+1]
-		frames[i].Func = next.Func
-	}
-	if len(frames) == 1 {
-		frames[0].Func = "??"
-		return frames, nil
-	}
-	return frames[:len(frames)-1], nil
+	return frames
 }
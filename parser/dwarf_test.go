@@ -0,0 +1,73 @@
+// =============================================================================
+//  @@-COPYRIGHT-START-@@
+//
+//  Copyright (c) 2024, Qualcomm Innovation Center, Inc. All rights reserved.
+//
+//  Redistribution and use in source and binary forms, with or without
+//  modification, are permitted provided that the following conditions are met:
+//
+//  1. Redistributions of source code must retain the above copyright notice,
+//     this list of conditions and the following disclaimer.
+//
+//  2. Redistributions in binary form must reproduce the above copyright notice,
+//     this list of conditions and the following disclaimer in the documentation
+//     and/or other materials provided with the distribution.
+//
+//  3. Neither the name of the copyright holder nor the names of its contributors
+//     may be used to endorse or promote products derived from this software
+//     without specific prior written permission.
+//
+//  THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS"
+//  AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE
+//  IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE
+//  ARE DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT HOLDER OR CONTRIBUTORS BE
+//  LIABLE FOR ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR
+//  CONSEQUENTIAL DAMAGES (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF
+//  SUBSTITUTE GOODS OR SERVICES; LOSS OF USE, DATA, OR PROFITS; OR BUSINESS
+//  INTERRUPTION) HOWEVER CAUSED AND ON ANY THEORY OF LIABILITY, WHETHER IN
+//  CONTRACT, STRICT LIABILITY, OR TORT (INCLUDING NEGLIGENCE OR OTHERWISE)
+//  ARISING IN ANY WAY OUT OF THE USE OF THIS SOFTWARE, EVEN IF ADVISED OF THE
+//  POSSIBILITY OF SUCH DAMAGE.
+//
+//  SPDX-License-Identifier: BSD-3-Clause
+//
+//  @@-COPYRIGHT-END-@@
+// =============================================================================
+
+package dwarfparser
+
+import (
+	"path/filepath"
+	"testing"
+)
+
+// testdata/cold is built from cold.c with work split into a hot range at
+// 0x401010 and a cold one at 0x401002, below it.
+func TestFuncOffset(t *testing.T) {
+	b, err := Open(filepath.Join("testdata", "cold"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer b.Close()
+	for _, tt := range []struct {
+		pc     uint64
+		fn     string
+		entry  uint64
+		offset uint64
+		size   uint64
+	}{
+		{0x401001, "fail", 0x401000, 0x1, 0x2},
+		{0x401004, "work", 0x401010, 0x2, 0x1a},
+		{0x401014, "work", 0x401010, 0x12, 0x1a},
+	} {
+		frames, err := b.Addr2line(tt.pc)
+		if err != nil {
+			t.Fatalf("Addr2line(0x%x): %v", tt.pc, err)
+		}
+		f := frames[0]
+		if f.Func != tt.fn || f.FuncEntry != tt.entry || f.FuncOffset != tt.offset || f.FuncSize != tt.size {
+			t.Errorf("Addr2line(0x%x) = %v+0x%x/0x%x entry 0x%x, want %v+0x%x/0x%x entry 0x%x",
+				tt.pc, f.Func, f.FuncOffset, f.FuncSize, f.FuncEntry, tt.fn, tt.offset, tt.size, tt.entry)
+		}
+	}
+}
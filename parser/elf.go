@@ -37,10 +37,22 @@
 package dwarfparser
 
 import (
+	"compress/zlib"
 	"debug/elf"
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
 	"fmt"
+	"io"
+	"math"
+	"slices"
+	"strings"
 )
 
+var ErrNoBuildID = errors.New("no GNU build-id")
+
+const ntGNUBuildID = 3
+
 func GetSectionByName(file *elf.File, sec string) (*elf.Section, error) {
 	s := file.Section(sec)
 	if s == nil {
@@ -49,12 +61,99 @@ func GetSectionByName(file *elf.File, sec string) (*elf.Section, error) {
 	return s, nil
 }
 
+// debugSection returns the section of f with the given .debug_* name, or
+// its GNU compressed .zdebug_* twin.
+func debugSection(f *elf.File, name string) *elf.Section {
+	if s := f.Section(name); s != nil {
+		return s
+	}
+	if suffix, ok := strings.CutPrefix(name, ".debug_"); ok {
+		return f.Section(".zdebug_" + suffix)
+	}
+	return nil
+}
+
+// sectionData returns the contents of s. Uncompressed sections of a mapped
+// binary are sliced out of the mapping instead of being copied, compressed
+// ones are decompressed by decompressSection.
+func (b *Binary) sectionData(s *elf.Section) ([]byte, error) {
+	if s.Type == elf.SHT_NOBITS {
+		return s.Data()
+	}
+	if s.Flags&elf.SHF_COMPRESSED != 0 || strings.HasPrefix(s.Name, ".zdebug") {
+		return b.decompressSection(s)
+	}
+	if b.mapping != nil {
+		end := s.Offset + s.FileSize
+		if end >= s.Offset && end <= uint64(len(b.mapping)) {
+			return b.mapping[s.Offset:end:end], nil
+		}
+	}
+	return s.Data()
+}
+
+// A size claimed by a compression header is trusted for allocating the
+// whole buffer up front only up to this ratio to the compressed size.
+const maxCompressionRatio = 1 << 12
+
+// decompressSection decompresses s, a SHF_COMPRESSED section with zlib or
+// zstd data or a GNU .zdebug_* section, as a stream. The buffer is
+// allocated at the uncompressed size, so that the data isn't copied around
+// while growing, unless that size is implausible for the compressed size,
+// in which case the buffer grows as data comes in.
+func (b *Binary) decompressSection(s *elf.Section) ([]byte, error) {
+	var r io.Reader
+	size := s.Size
+	if s.Flags&elf.SHF_COMPRESSED != 0 {
+		r = s.Open()
+	} else {
+		// "ZLIB" followed by the big-endian uncompressed size.
+		var hdr [12]byte
+		if _, err := s.ReadAt(hdr[:], 0); err != nil || string(hdr[:4]) != "ZLIB" {
+			return nil, fmt.Errorf("%v: %v: bad compression header", b.Path, s.Name)
+		}
+		size = binary.BigEndian.Uint64(hdr[4:])
+		zr, err := zlib.NewReader(io.NewSectionReader(s, 12, int64(s.FileSize)-12))
+		if err != nil {
+			return nil, fmt.Errorf("%v: %v: %w", b.Path, s.Name, err)
+		}
+		defer zr.Close()
+		r = zr
+	}
+	if size > math.MaxInt {
+		return nil, fmt.Errorf("%v: %v: uncompressed size 0x%x too large", b.Path, s.Name, size)
+	}
+	data := make([]byte, 0, min(size, s.FileSize*maxCompressionRatio))
+	for uint64(len(data)) < size {
+		if len(data) == cap(data) {
+			data = slices.Grow(data, int(min(max(uint64(len(data)), 512), size-uint64(len(data)))))
+		}
+		n, err := r.Read(data[len(data):min(uint64(cap(data)), size)])
+		data = data[:len(data)+n]
+		if err == io.EOF && uint64(len(data)) < size {
+			err = io.ErrUnexpectedEOF
+		}
+		if err != nil && err != io.EOF {
+			return nil, fmt.Errorf("%v: decompressing %v: %w", b.Path, s.Name, err)
+		}
+	}
+	return data, nil
+}
+
 func GetSectionIdx(path, sec string) (int, error) {
-	f, err := elf.Open(path)
+	b, release, err := openCached(path)
+	if err != nil {
+		return -1, err
+	}
+	defer release()
+	return b.GetSectionIdx(sec)
+}
+
+func (b *Binary) GetSectionIdx(sec string) (int, error) {
+	f, err := b.File()
 	if err != nil {
 		return -1, err
 	}
-	defer f.Close()
 	for i, s := range f.Sections {
 		if s.Name == sec {
 			return i, nil
@@ -62,3 +161,45 @@ func GetSectionIdx(path, sec string) (int, error) {
 	}
 	return -1, fmt.Errorf("not found index for section %v", sec)
 }
+
+func BuildID(path string) (string, error) {
+	b, release, err := openCached(path)
+	if err != nil {
+		return "", err
+	}
+	defer release()
+	return b.BuildID()
+}
+
+// BuildID returns the hex encoded NT_GNU_BUILD_ID note of the binary.
+func (b *Binary) BuildID() (string, error) {
+	f, err := b.File()
+	if err != nil {
+		return "", err
+	}
+	for _, s := range f.Sections {
+		if s.Type != elf.SHT_NOTE {
+			continue
+		}
+		data, err := s.Data()
+		if err != nil {
+			return "", err
+		}
+		for len(data) >= 12 {
+			nameSize := int(f.ByteOrder.Uint32(data[0:]))
+			descSize := int(f.ByteOrder.Uint32(data[4:]))
+			typ := f.ByteOrder.Uint32(data[8:])
+			data = data[12:]
+			nameEnd := (nameSize + 3) &^ 3
+			descEnd := nameEnd + (descSize+3)&^3
+			if nameSize < 0 || descSize < 0 || descEnd > len(data) {
+				break
+			}
+			if typ == ntGNUBuildID && nameSize == 4 && string(data[:nameSize]) == "GNU\x00" {
+				return hex.EncodeToString(data[nameEnd : nameEnd+descSize]), nil
+			}
+			data = data[descEnd:]
+		}
+	}
+	return "", fmt.Errorf("%v: %w", b.Path, ErrNoBuildID)
+}
@@ -0,0 +1,118 @@
+// =============================================================================
+//  @@-COPYRIGHT-START-@@
+//
+//  Copyright (c) 2024, Qualcomm Innovation Center, Inc. All rights reserved.
+//
+//  Redistribution and use in source and binary forms, with or without
+//  modification, are permitted provided that the following conditions are met:
+//
+//  1. Redistributions of source code must retain the above copyright notice,
+//     this list of conditions and the following disclaimer.
+//
+//  2. Redistributions in binary form must reproduce the above copyright notice,
+//     this list of conditions and the following disclaimer in the documentation
+//     and/or other materials provided with the distribution.
+//
+//  3. Neither the name of the copyright holder nor the names of its contributors
+//     may be used to endorse or promote products derived from this software
+//     without specific prior written permission.
+//
+//  THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS"
+//  AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE
+//  IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE
+//  ARE DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT HOLDER OR CONTRIBUTORS BE
+//  LIABLE FOR ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR
+//  CONSEQUENTIAL DAMAGES (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF
+//  SUBSTITUTE GOODS OR SERVICES; LOSS OF USE, DATA, OR PROFITS; OR BUSINESS
+//  INTERRUPTION) HOWEVER CAUSED AND ON ANY THEORY OF LIABILITY, WHETHER IN
+//  CONTRACT, STRICT LIABILITY, OR TORT (INCLUDING NEGLIGENCE OR OTHERWISE)
+//  ARISING IN ANY WAY OUT OF THE USE OF THIS SOFTWARE, EVEN IF ADVISED OF THE
+//  POSSIBILITY OF SUCH DAMAGE.
+//
+//  SPDX-License-Identifier: BSD-3-Clause
+//
+//  @@-COPYRIGHT-END-@@
+// =============================================================================
+
+package dwarfparser
+
+import (
+	"cmp"
+	"debug/dwarf"
+	"errors"
+	"fmt"
+	"slices"
+)
+
+var (
+	ErrNoDebugInfo   = errors.New("no debug info")
+	ErrNoCompileUnit = errors.New("pc is outside any compile unit")
+	ErrNoSubprogram  = errors.New("no subprogram covers pc")
+	ErrNoLineEntry   = errors.New("no line entry for pc")
+	ErrMalformedDIE  = errors.New("malformed DIE")
+	ErrNoSplitDWARF  = errors.New("no .dwo or .dwp file for split unit")
+)
+
+// LookupError is returned when pc can't be fully symbolized. Err is one of
+// the Err* variables above or the underlying error.
+type LookupError struct {
+	PC  uint64
+	Err error
+}
+
+func (e *LookupError) Error() string {
+	return fmt.Sprintf("0x%x: %v", e.PC, e.Err)
+}
+
+func (e *LookupError) Unwrap() error {
+	return e.Err
+}
+
+// DIEError reports a DIE which doesn't have the attributes the parser expects.
+// It matches ErrMalformedDIE with errors.Is.
+type DIEError struct {
+	Offset dwarf.Offset
+	Msg    string
+}
+
+func (e *DIEError) Error() string {
+	return fmt.Sprintf("%v at 0x%x: %v", ErrMalformedDIE, e.Offset, e.Msg)
+}
+
+func (e *DIEError) Is(target error) bool {
+	return target == ErrMalformedDIE
+}
+
+func Diagnostics(path string) ([]*DIEError, error) {
+	b, release, err := openCached(path)
+	if err != nil {
+		return nil, err
+	}
+	defer release()
+	return b.Diagnostics(), nil
+}
+
+// Diagnostics returns the malformed attributes met so far which were
+// ignored instead of failing the lookup, e.g. a DW_AT_decl_file out of the
+// file table, ordered by DIE offset.
+func (b *Binary) Diagnostics() []*DIEError {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	var diags []*DIEError
+	for d := range b.diagnostics {
+		diags = append(diags, &d)
+	}
+	slices.SortFunc(diags, func(a, b *DIEError) int {
+		return cmp.Or(cmp.Compare(a.Offset, b.Offset), cmp.Compare(a.Msg, b.Msg))
+	})
+	return diags
+}
+
+func (b *Binary) diagnose(off dwarf.Offset, format string, args ...any) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.diagnostics == nil {
+		b.diagnostics = make(map[DIEError]bool)
+	}
+	b.diagnostics[DIEError{Offset: off, Msg: fmt.Sprintf(format, args...)}] = true
+}
@@ -0,0 +1,112 @@
+// =============================================================================
+//  @@-COPYRIGHT-START-@@
+//
+//  Copyright (c) 2024, Qualcomm Innovation Center, Inc. All rights reserved.
+//
+//  Redistribution and use in source and binary forms, with or without
+//  modification, are permitted provided that the following conditions are met:
+//
+//  1. Redistributions of source code must retain the above copyright notice,
+//     this list of conditions and the following disclaimer.
+//
+//  2. Redistributions in binary form must reproduce the above copyright notice,
+//     this list of conditions and the following disclaimer in the documentation
+//     and/or other materials provided with the distribution.
+//
+//  3. Neither the name of the copyright holder nor the names of its contributors
+//     may be used to endorse or promote products derived from this software
+//     without specific prior written permission.
+//
+//  THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS"
+//  AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE
+//  IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE
+//  ARE DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT HOLDER OR CONTRIBUTORS BE
+//  LIABLE FOR ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR
+//  CONSEQUENTIAL DAMAGES (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF
+//  SUBSTITUTE GOODS OR SERVICES; LOSS OF USE, DATA, OR PROFITS; OR BUSINESS
+//  INTERRUPTION) HOWEVER CAUSED AND ON ANY THEORY OF LIABILITY, WHETHER IN
+//  CONTRACT, STRICT LIABILITY, OR TORT (INCLUDING NEGLIGENCE OR OTHERWISE)
+//  ARISING IN ANY WAY OUT OF THE USE OF THIS SOFTWARE, EVEN IF ADVISED OF THE
+//  POSSIBILITY OF SUCH DAMAGE.
+//
+//  SPDX-License-Identifier: BSD-3-Clause
+//
+//  @@-COPYRIGHT-END-@@
+// =============================================================================
+
+package dwarfparser
+
+import (
+	"debug/dwarf"
+	"fmt"
+	"strings"
+)
+
+// Explanation records how Binary.Explain resolved a pc: the compile unit
+// and subprogram covering it, every inlined subroutine of the subprogram
+// that was considered and the .debug_line row used for the innermost frame.
+// Fields are nil when the lookup stopped before reaching them.
+type Explanation struct {
+	PC          uint64
+	CompileUnit *DWARFCompileUnit
+	Subprogram  *DWARFFunction
+	Inlines     []*DWARFFunction
+	Line        *dwarf.LineEntry
+	// LineExact is false if Line is the nearest row below PC.
+	LineExact bool
+
+	prefixMaps []PrefixMap
+}
+
+func (ex *Explanation) String() string {
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "explain 0x%x\n", ex.PC)
+	if cu := ex.CompileUnit; cu != nil {
+		fmt.Fprintf(&sb, "  compile unit 0x%x %v comp_dir %v ranges %v\n",
+			cu.Entry.Offset, cu.Name, ex.remap(cu.CompDir), formatRanges(cu.Ranges))
+	} else {
+		fmt.Fprintf(&sb, "  compile unit: none\n")
+	}
+	if le := ex.Line; le != nil {
+		match := "nearest lower row"
+		if ex.LineExact {
+			match = "exact row"
+		}
+		var file string
+		if le.File != nil && ex.CompileUnit != nil {
+			file = ex.remap(ex.CompileUnit.resolvePath(le.File.Name))
+		}
+		fmt.Fprintf(&sb, "  line 0x%x %v:%v column %v (%v)\n", le.Address, file, le.Line, le.Column, match)
+	} else if ex.CompileUnit != nil {
+		fmt.Fprintf(&sb, "  line: none\n")
+	}
+	if sp := ex.Subprogram; sp != nil {
+		fmt.Fprintf(&sb, "  subprogram 0x%x %v ranges %v decl %v:%v\n",
+			sp.Offset, sp.Name, formatRanges(sp.Ranges), ex.remap(sp.DeclFile), sp.DeclLine)
+	} else if ex.CompileUnit != nil {
+		fmt.Fprintf(&sb, "  subprogram: none\n")
+	}
+	for _, f := range ex.Inlines {
+		match := "does not contain pc"
+		if f.hasPC(ex.PC) {
+			match = "contains pc"
+		}
+		fmt.Fprintf(&sb, "  %vinlined 0x%x %v ranges %v call %v:%v (%v)\n", strings.Repeat(" ", max(f.Depth-ex.Subprogram.Depth, 0)),
+			f.Offset, f.Name, formatRanges(f.Ranges), ex.remap(f.CallFile), f.CallLine, match)
+	}
+	return sb.String()
+}
+
+// remap applies the prefix maps of the Binary explaining ex to name, like
+// to the paths of its frames.
+func (ex *Explanation) remap(name string) string {
+	return remapPath(ex.prefixMaps, name)
+}
+
+func formatRanges(ranges [][2]uint64) string {
+	var parts []string
+	for _, r := range ranges {
+		parts = append(parts, fmt.Sprintf("[0x%x, 0x%x)", r[0], r[1]))
+	}
+	return strings.Join(parts, " ")
+}
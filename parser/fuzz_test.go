@@ -0,0 +1,140 @@
+// =============================================================================
+//  @@-COPYRIGHT-START-@@
+//
+//  Copyright (c) 2024, Qualcomm Innovation Center, Inc. All rights reserved.
+//
+//  Redistribution and use in source and binary forms, with or without
+//  modification, are permitted provided that the following conditions are met:
+//
+//  1. Redistributions of source code must retain the above copyright notice,
+//     this list of conditions and the following disclaimer.
+//
+//  2. Redistributions in binary form must reproduce the above copyright notice,
+//     this list of conditions and the following disclaimer in the documentation
+//     and/or other materials provided with the distribution.
+//
+//  3. Neither the name of the copyright holder nor the names of its contributors
+//     may be used to endorse or promote products derived from this software
+//     without specific prior written permission.
+//
+//  THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS"
+//  AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE
+//  IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE
+//  ARE DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT HOLDER OR CONTRIBUTORS BE
+//  LIABLE FOR ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR
+//  CONSEQUENTIAL DAMAGES (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF
+//  SUBSTITUTE GOODS OR SERVICES; LOSS OF USE, DATA, OR PROFITS; OR BUSINESS
+//  INTERRUPTION) HOWEVER CAUSED AND ON ANY THEORY OF LIABILITY, WHETHER IN
+//  CONTRACT, STRICT LIABILITY, OR TORT (INCLUDING NEGLIGENCE OR OTHERWISE)
+//  ARISING IN ANY WAY OUT OF THE USE OF THIS SOFTWARE, EVEN IF ADVISED OF THE
+//  POSSIBILITY OF SUCH DAMAGE.
+//
+//  SPDX-License-Identifier: BSD-3-Clause
+//
+//  @@-COPYRIGHT-END-@@
+// =============================================================================
+
+package dwarfparser
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// addSeeds adds the small objects in testdata, built from C and C++ with
+// DWARF 4, DWARF 5 and compressed debug sections and for several targets,
+// to the corpus of f.
+func addSeeds(f *testing.F) {
+	paths, err := filepath.Glob(filepath.Join("testdata", "*.o"))
+	if err != nil {
+		f.Fatal(err)
+	}
+	relocs, err := filepath.Glob(filepath.Join("testdata", "reloc", "*.o"))
+	if err != nil {
+		f.Fatal(err)
+	}
+	for _, path := range append(paths, relocs...) {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			f.Fatal(err)
+		}
+		f.Add(data)
+	}
+}
+
+// fuzzBinary returns the Binary of data, or skips inputs that aren't ELF.
+func fuzzBinary(t *testing.T, data []byte) *Binary {
+	b, err := NewBinary(bytes.NewReader(data), "fuzz")
+	if err != nil {
+		t.Skip()
+	}
+	t.Cleanup(func() {
+		b.Close()
+	})
+	return b
+}
+
+func FuzzUnits(f *testing.F) {
+	addSeeds(f)
+	f.Fuzz(func(t *testing.T, data []byte) {
+		b := fuzzBinary(t, data)
+		for _, err := range b.Units() {
+			if err != nil {
+				break
+			}
+		}
+		cus, err := b.FindAllCompileUnits()
+		if err != nil {
+			return
+		}
+		for _, cu := range cus {
+			if len(cu.Ranges) != 0 {
+				b.GetCompileUnitByAddr(cu.Ranges[0][0])
+			}
+		}
+		b.Diagnostics()
+	})
+}
+
+func FuzzFuncs(f *testing.F) {
+	addSeeds(f)
+	f.Fuzz(func(t *testing.T, data []byte) {
+		b := fuzzBinary(t, data)
+		for fn, err := range b.Funcs() {
+			if err != nil {
+				break
+			}
+			if fn.Type == 0 || fn.Name == "" {
+				t.Errorf("function at 0x%x without tag or name", fn.Offset)
+			}
+		}
+		b.LookupNamePrefix("")
+		b.Diagnostics()
+	})
+}
+
+func FuzzLineTable(f *testing.F) {
+	addSeeds(f)
+	f.Fuzz(func(t *testing.T, data []byte) {
+		b := fuzzBinary(t, data)
+		var pcs []uint64
+		for ent, err := range b.LineRows() {
+			if err != nil {
+				break
+			}
+			if len(pcs) < 64 {
+				pcs = append(pcs, ent.Address)
+			}
+		}
+		for _, pc := range pcs {
+			frames, _ := b.Addr2line(pc)
+			if len(frames) == 0 {
+				t.Errorf("no frames for 0x%x", pc)
+			}
+		}
+		b.SymbolizeMany(pcs)
+		b.Diagnostics()
+	})
+}
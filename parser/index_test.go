@@ -0,0 +1,117 @@
+// =============================================================================
+//  @@-COPYRIGHT-START-@@
+//
+//  Copyright (c) 2024, Qualcomm Innovation Center, Inc. All rights reserved.
+//
+//  Redistribution and use in source and binary forms, with or without
+//  modification, are permitted provided that the following conditions are met:
+//
+//  1. Redistributions of source code must retain the above copyright notice,
+//     this list of conditions and the following disclaimer.
+//
+//  2. Redistributions in binary form must reproduce the above copyright notice,
+//     this list of conditions and the following disclaimer in the documentation
+//     and/or other materials provided with the distribution.
+//
+//  3. Neither the name of the copyright holder nor the names of its contributors
+//     may be used to endorse or promote products derived from this software
+//     without specific prior written permission.
+//
+//  THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS"
+//  AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE
+//  IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE
+//  ARE DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT HOLDER OR CONTRIBUTORS BE
+//  LIABLE FOR ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR
+//  CONSEQUENTIAL DAMAGES (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF
+//  SUBSTITUTE GOODS OR SERVICES; LOSS OF USE, DATA, OR PROFITS; OR BUSINESS
+//  INTERRUPTION) HOWEVER CAUSED AND ON ANY THEORY OF LIABILITY, WHETHER IN
+//  CONTRACT, STRICT LIABILITY, OR TORT (INCLUDING NEGLIGENCE OR OTHERWISE)
+//  ARISING IN ANY WAY OUT OF THE USE OF THIS SOFTWARE, EVEN IF ADVISED OF THE
+//  POSSIBILITY OF SUCH DAMAGE.
+//
+//  SPDX-License-Identifier: BSD-3-Clause
+//
+//  @@-COPYRIGHT-END-@@
+// =============================================================================
+
+package dwarfparser
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"path/filepath"
+	"slices"
+	"testing"
+)
+
+// testdata/inl is linked from inl1.c and inl2.c, with twice inlined into
+// start. It has a build-id.
+func TestIndexRoundTrip(t *testing.T) {
+	b, err := Open(filepath.Join("testdata", "inl"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer b.Close()
+	id, err := b.BuildID()
+	if err != nil {
+		t.Fatal(err)
+	}
+	built, err := b.BuildIndex(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	var buf bytes.Buffer
+	if err := built.Write(&buf); err != nil {
+		t.Fatal(err)
+	}
+	idx, err := ReadIndex(bytes.NewReader(buf.Bytes()), id, b.Path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer idx.Close()
+	if idx.BuildID != id {
+		t.Errorf("BuildID = %v, want %v", idx.BuildID, id)
+	}
+	var inlined bool
+	for pc := uint64(0x400ff0); pc < 0x401030; pc++ {
+		want, wantErr := b.Addr2line(pc)
+		got, err := idx.Addr2line(pc)
+		if (err != nil) != (wantErr != nil) || !slices.Equal(got, want) {
+			t.Errorf("Addr2line(0x%x) of the index = %v, %v, want %v, %v", pc, got, err, want, wantErr)
+		}
+		inlined = inlined || len(got) > 1
+	}
+	if !inlined {
+		t.Error("no inlined frames")
+	}
+
+	for _, tt := range []struct {
+		name    string
+		data    func([]byte) []byte
+		buildID string
+		err     error
+	}{
+		{"other build-id", slices.Clone[[]byte], "0123456789", ErrStaleIndex},
+		{"any build-id", slices.Clone[[]byte], "", nil},
+		{"other version", func(data []byte) []byte {
+			data = slices.Clone(data)
+			data[len(indexMagic)]++
+			return data
+		}, id, ErrStaleIndex},
+		{"bad magic", func(data []byte) []byte {
+			return append([]byte("x"), data[1:]...)
+		}, id, ErrBadIndex},
+		{"truncated", func(data []byte) []byte {
+			return data[:len(data)/2]
+		}, id, ErrBadIndex},
+	} {
+		read, err := ReadIndex(bytes.NewReader(tt.data(buf.Bytes())), tt.buildID, b.Path)
+		if !errors.Is(err, tt.err) {
+			t.Errorf("%v: ReadIndex() = %v, want %v", tt.name, err, tt.err)
+		}
+		if err == nil {
+			read.Close()
+		}
+	}
+}
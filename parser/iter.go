@@ -0,0 +1,225 @@
+// =============================================================================
+//  @@-COPYRIGHT-START-@@
+//
+//  Copyright (c) 2024, Qualcomm Innovation Center, Inc. All rights reserved.
+//
+//  Redistribution and use in source and binary forms, with or without
+//  modification, are permitted provided that the following conditions are met:
+//
+//  1. Redistributions of source code must retain the above copyright notice,
+//     this list of conditions and the following disclaimer.
+//
+//  2. Redistributions in binary form must reproduce the above copyright notice,
+//     this list of conditions and the following disclaimer in the documentation
+//     and/or other materials provided with the distribution.
+//
+//  3. Neither the name of the copyright holder nor the names of its contributors
+//     may be used to endorse or promote products derived from this software
+//     without specific prior written permission.
+//
+//  THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS"
+//  AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE
+//  IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE
+//  ARE DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT HOLDER OR CONTRIBUTORS BE
+//  LIABLE FOR ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR
+//  CONSEQUENTIAL DAMAGES (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF
+//  SUBSTITUTE GOODS OR SERVICES; LOSS OF USE, DATA, OR PROFITS; OR BUSINESS
+//  INTERRUPTION) HOWEVER CAUSED AND ON ANY THEORY OF LIABILITY, WHETHER IN
+//  CONTRACT, STRICT LIABILITY, OR TORT (INCLUDING NEGLIGENCE OR OTHERWISE)
+//  ARISING IN ANY WAY OUT OF THE USE OF THIS SOFTWARE, EVEN IF ADVISED OF THE
+//  POSSIBILITY OF SUCH DAMAGE.
+//
+//  SPDX-License-Identifier: BSD-3-Clause
+//
+//  @@-COPYRIGHT-END-@@
+// =============================================================================
+
+package dwarfparser
+
+import (
+	"debug/dwarf"
+	"fmt"
+	"io"
+	"iter"
+)
+
+// CompileUnits walks the compile and skeleton units of b in .debug_info
+// order without keeping them, units without name or address ranges are
+// skipped. Partial and type units are walked by Units. The walk stops at the
+// first error, which is yielded with a nil unit.
+func (b *Binary) CompileUnits() iter.Seq2[*DWARFCompileUnit, error] {
+	return func(yield func(*DWARFCompileUnit, error) bool) {
+		for cu, err := range b.Units() {
+			if err != nil {
+				yield(nil, err)
+				return
+			}
+			if !cu.isCompileUnit() {
+				continue
+			}
+			if !yield(cu, nil) {
+				return
+			}
+		}
+	}
+}
+
+func (cu *DWARFCompileUnit) isCompileUnit() bool {
+	tag := cu.Entry.Tag
+	return (tag == dwarf.TagCompileUnit || tag == dwarf.TagSkeletonUnit) && cu.Name != "" && len(cu.Ranges) != 0
+}
+
+// Funcs walks the subprograms and inlined subroutines of all compile units
+// in .debug_info order, see DWARFCompileUnit.Funcs. Besides the current
+// unit, the walk holds the offsets of all units once a function refers to
+// another unit, and the file table and scope names of each unit walked,
+// which b keeps for later lookups.
+func (b *Binary) Funcs() iter.Seq2[*DWARFFunction, error] {
+	return func(yield func(*DWARFFunction, error) bool) {
+		for cu, err := range b.CompileUnits() {
+			if err != nil {
+				yield(nil, err)
+				return
+			}
+			for f, err := range cu.Funcs() {
+				if !yield(f, err) || err != nil {
+					return
+				}
+			}
+		}
+	}
+}
+
+// Funcs walks the subprograms and inlined subroutines of cu in DIE order.
+// Depth is the nesting level of the DIE below the compile unit, functions
+// without address ranges are skipped. The DIEs of units imported by
+// DW_TAG_imported_unit are walked in place of the importing DIE, with
+// DwarfCompileUnit set to the imported unit. Of a skeleton unit, the split
+// unit is walked.
+func (cu *DWARFCompileUnit) Funcs() iter.Seq2[*DWARFFunction, error] {
+	return func(yield func(*DWARFFunction, error) bool) {
+		split, err := cu.splitUnit()
+		if err != nil {
+			yield(nil, err)
+			return
+		}
+		if split != nil {
+			cu = split
+		}
+		seen := map[unitKey]bool{cu.key(): true}
+		cu.walkFuncs(0, seen, yield)
+	}
+}
+
+// unitKey identifies a unit, which may be read more than once.
+type unitKey struct {
+	b   *Binary
+	off dwarf.Offset
+}
+
+func (cu *DWARFCompileUnit) key() unitKey {
+	return unitKey{cu.Binary, cu.Entry.Offset}
+}
+
+// walkFuncs is Funcs with the depth of the unit DIE and the units already
+// walked. It returns false once the walk was stopped.
+func (cu *DWARFCompileUnit) walkFuncs(base int, seen map[unitKey]bool, yield func(*DWARFFunction, error) bool) bool {
+	first := true
+	depth := base
+	r := cu.Dwarf.Reader()
+	r.Seek(cu.Entry.Offset)
+	for {
+		ent, err := r.Next()
+		if err != nil {
+			yield(nil, err)
+			return false
+		}
+		if ent == nil {
+			return true
+		}
+		var f *DWARFFunction
+		if isUnit(ent.Tag) {
+			if first {
+				first = false
+				goto loop
+			}
+			return true
+		} else if ent.Tag == dwarf.TagSubprogram {
+			f, err = cu.parseSubprogram(ent, depth)
+		} else if ent.Tag == dwarf.TagInlinedSubroutine {
+			if ent.Val(dwarf.AttrAbstractOrigin) == nil {
+				goto loop
+			}
+			f, err = cu.parseSubroutine(ent, depth)
+		} else if ent.Tag == dwarf.TagImportedUnit {
+			var u *DWARFCompileUnit
+			u, _, err = cu.refEntry(ent, dwarf.AttrImport)
+			if err == nil && u != nil && !seen[u.key()] {
+				seen[u.key()] = true
+				if !u.walkFuncs(depth-1, seen, yield) {
+					return false
+				}
+			}
+		} else if ent.Tag == 0 {
+			depth--
+		}
+		if err != nil {
+			yield(nil, err)
+			return false
+		}
+		if f != nil && len(f.Ranges) != 0 && !yield(f, nil) {
+			return false
+		}
+	loop:
+		if ent.Children {
+			depth++
+		}
+	}
+}
+
+// LineRows walks the rows of the line table of cu in the order of the line
+// number program, including end_sequence rows.
+func (cu *DWARFCompileUnit) LineRows() iter.Seq2[*dwarf.LineEntry, error] {
+	return func(yield func(*dwarf.LineEntry, error) bool) {
+		r, err := cu.Dwarf.LineReader(cu.Entry)
+		if err != nil {
+			yield(nil, err)
+			return
+		}
+		if r == nil {
+			yield(nil, fmt.Errorf("failed to get line reader for %v 0x%x", cu.FilePath, cu.Entry.Offset))
+			return
+		}
+		for {
+			ent := &dwarf.LineEntry{}
+			err := r.Next(ent)
+			if err == io.EOF {
+				return
+			}
+			if err != nil {
+				yield(nil, err)
+				return
+			}
+			if !yield(ent, nil) {
+				return
+			}
+		}
+	}
+}
+
+// LineRows walks the line table rows of all compile units.
+func (b *Binary) LineRows() iter.Seq2[*dwarf.LineEntry, error] {
+	return func(yield func(*dwarf.LineEntry, error) bool) {
+		for cu, err := range b.CompileUnits() {
+			if err != nil {
+				yield(nil, err)
+				return
+			}
+			for ent, err := range cu.LineRows() {
+				if !yield(ent, err) || err != nil {
+					return
+				}
+			}
+		}
+	}
+}
@@ -34,27 +34,29 @@
 //  @@-COPYRIGHT-END-@@
 // =============================================================================
 
-package main
+package dwarfparser
 
 import (
 	"bufio"
 	"fmt"
 	"io"
+	"iter"
 	"os/exec"
+	"slices"
 	"strconv"
 	"strings"
+	"sync"
 )
 
-type Symbolizer struct {
-	subprocs map[string]*subprocess
-}
+// LLVMAddr2line is a Provider running llvm-addr2line on one binary.
+// Only Addr2line is supported.
+type LLVMAddr2line struct {
+	Path string
+	Tool string
 
-type Frame struct {
-	PC     uint64
-	Func   string
-	File   string
-	Line   int
-	Inline bool
+	mu         sync.Mutex
+	sub        *subprocess
+	prefixMaps []PrefixMap
 }
 
 type subprocess struct {
@@ -65,35 +67,83 @@ type subprocess struct {
 	scanner *bufio.Scanner
 }
 
-func NewSymbolizer() *Symbolizer {
-	return &Symbolizer{}
+func NewLLVMAddr2line(path string) *LLVMAddr2line {
+	return &LLVMAddr2line{
+		Path: path,
+		Tool: "llvm-addr2line",
+	}
 }
 
-func (s *Symbolizer) SymbolizeArray(bin string, pcs []uint64) ([]Frame, error) {
-	sub, err := s.getSubprocess(bin)
+func (s *LLVMAddr2line) Addr2line(pc uint64) ([]Frame, error) {
+	frames, err := s.SymbolizeArray([]uint64{pc})
+	if err != nil {
+		return []Frame{unknownFrame(pc)}, err
+	}
+	if len(frames) == 0 {
+		return []Frame{unknownFrame(pc)}, &LookupError{PC: pc, Err: ErrNoSubprogram}
+	}
+	return frames, nil
+}
+
+func (s *LLVMAddr2line) FuncsByName(name string) ([]*DWARFFunction, error) {
+	return nil, ErrNotSupported
+}
+
+func (s *LLVMAddr2line) Funcs() iter.Seq2[*DWARFFunction, error] {
+	return func(yield func(*DWARFFunction, error) bool) {
+		yield(nil, ErrNotSupported)
+	}
+}
+
+// SymbolizeArray symbolizes pcs with a single round trip to the subprocess.
+// Frames of all pcs are returned in one slice, innermost first for each pc.
+func (s *LLVMAddr2line) SymbolizeArray(pcs []uint64) ([]Frame, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	sub, err := s.getSubprocess()
 	if err != nil {
 		return nil, err
 	}
-	return symbolize(sub.input, sub.scanner, pcs)
+	frames, err := symbolize(sub.input, sub.scanner, pcs)
+	remapFrames(s.prefixMaps, frames)
+	return frames, err
+}
+
+// SetPrefixMaps is Binary.SetPrefixMaps for the frames returned by s.
+func (s *LLVMAddr2line) SetPrefixMaps(maps ...PrefixMap) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.prefixMaps = slices.Clone(maps)
 }
 
-func (s *Symbolizer) Close() {
-	for _, sub := range s.subprocs {
-		sub.stdin.Close()
-		sub.stdout.Close()
-		// nolint: errcheck
-		sub.cmd.Process.Kill()
-		// nolint: errcheck
-		sub.cmd.Wait()
+func (s *LLVMAddr2line) PrefixMaps() []PrefixMap {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return slices.Clone(s.prefixMaps)
+}
+
+func (s *LLVMAddr2line) Close() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.sub == nil {
+		return nil
 	}
+	sub := s.sub
+	s.sub = nil
+	sub.stdin.Close()
+	sub.stdout.Close()
+	// nolint: errcheck
+	sub.cmd.Process.Kill()
+	// nolint: errcheck
+	sub.cmd.Wait()
+	return nil
 }
 
-func (s *Symbolizer) getSubprocess(bin string) (*subprocess, error) {
-	if sub := s.subprocs[bin]; sub != nil {
-		return sub, nil
+func (s *LLVMAddr2line) getSubprocess() (*subprocess, error) {
+	if s.sub != nil {
+		return s.sub, nil
 	}
-	addr2line := "llvm-addr2line"
-	cmd := exec.Command(addr2line, "-afi", "-e", bin)
+	cmd := exec.Command(s.Tool, "-afi", "-e", s.Path)
 	stdin, err := cmd.StdinPipe()
 	if err != nil {
 		return nil, err
@@ -115,10 +165,7 @@ func (s *Symbolizer) getSubprocess(bin string) (*subprocess, error) {
 		input:   bufio.NewWriter(stdin),
 		scanner: bufio.NewScanner(stdout),
 	}
-	if s.subprocs == nil {
-		s.subprocs = make(map[string]*subprocess)
-	}
-	s.subprocs[bin] = sub
+	s.sub = sub
 	return sub, nil
 }
 
@@ -203,19 +250,18 @@ func parse(s *bufio.Scanner) ([]Frame, error) {
 		if err != nil || fn == "" || fn == "??" || file == "" || file == "??" || line <= 0 {
 			continue
 		}
+		// Like assembleFrames, the innermost frame is the location of pc and
+		// the others are call sites of inlined functions.
 		frames = append(frames, Frame{
 			PC:     pc,
 			Func:   fn,
 			File:   file,
 			Line:   line,
-			Inline: true,
+			Inline: len(frames) != 0,
 		})
 	}
 	if err := s.Err(); err != nil {
 		return nil, err
 	}
-	if len(frames) != 0 {
-		frames[len(frames)-1].Inline = false
-	}
 	return frames, nil
 }
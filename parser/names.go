@@ -0,0 +1,687 @@
+// =============================================================================
+//  @@-COPYRIGHT-START-@@
+//
+//  Copyright (c) 2024, Qualcomm Innovation Center, Inc. All rights reserved.
+//
+//  Redistribution and use in source and binary forms, with or without
+//  modification, are permitted provided that the following conditions are met:
+//
+//  1. Redistributions of source code must retain the above copyright notice,
+//     this list of conditions and the following disclaimer.
+//
+//  2. Redistributions in binary form must reproduce the above copyright notice,
+//     this list of conditions and the following disclaimer in the documentation
+//     and/or other materials provided with the distribution.
+//
+//  3. Neither the name of the copyright holder nor the names of its contributors
+//     may be used to endorse or promote products derived from this software
+//     without specific prior written permission.
+//
+//  THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS"
+//  AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE
+//  IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE
+//  ARE DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT HOLDER OR CONTRIBUTORS BE
+//  LIABLE FOR ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR
+//  CONSEQUENTIAL DAMAGES (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF
+//  SUBSTITUTE GOODS OR SERVICES; LOSS OF USE, DATA, OR PROFITS; OR BUSINESS
+//  INTERRUPTION) HOWEVER CAUSED AND ON ANY THEORY OF LIABILITY, WHETHER IN
+//  CONTRACT, STRICT LIABILITY, OR TORT (INCLUDING NEGLIGENCE OR OTHERWISE)
+//  ARISING IN ANY WAY OUT OF THE USE OF THIS SOFTWARE, EVEN IF ADVISED OF THE
+//  POSSIBILITY OF SUCH DAMAGE.
+//
+//  SPDX-License-Identifier: BSD-3-Clause
+//
+//  @@-COPYRIGHT-END-@@
+// =============================================================================
+
+package dwarfparser
+
+import (
+	"context"
+	"debug/dwarf"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"math"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// NameEntry is a named DIE: a subprogram, an inlined subroutine, a file or
+// namespace scope variable or a type. Name is the DW_AT_name of the DIE or
+// of its abstract origin.
+type NameEntry struct {
+	Name   string
+	Tag    dwarf.Tag
+	CU     *DWARFCompileUnit
+	Offset dwarf.Offset
+}
+
+// nameIndex holds the sorted names of a binary. The entries of a name are
+// either known, or are found by walking the CUs listed for it.
+type nameIndex struct {
+	names   []string
+	entries [][]NameEntry
+	cus     [][]*DWARFCompileUnit
+}
+
+func LookupName(path, name string) ([]NameEntry, error) {
+	b, release, err := openCached(path)
+	if err != nil {
+		return nil, err
+	}
+	defer release()
+	return b.LookupName(name)
+}
+
+func LookupNamePrefix(path, prefix string) ([]NameEntry, error) {
+	b, release, err := openCached(path)
+	if err != nil {
+		return nil, err
+	}
+	defer release()
+	return b.LookupNamePrefix(prefix)
+}
+
+func LookupNameRegexp(path string, re *regexp.Regexp) ([]NameEntry, error) {
+	b, release, err := openCached(path)
+	if err != nil {
+		return nil, err
+	}
+	defer release()
+	return b.LookupNameRegexp(re)
+}
+
+// LookupName returns the DIEs named name, ordered by offset. Entries of split
+// units and supplementary files, which have offsets of their own, are
+// grouped by file. It uses .debug_names or .gdb_index when the binary has
+// them.
+func (b *Binary) LookupName(name string) ([]NameEntry, error) {
+	idx, err := b.nameIndex()
+	if err != nil {
+		return nil, err
+	}
+	i := sort.SearchStrings(idx.names, name)
+	if i < len(idx.names) && idx.names[i] == name {
+		return b.lookupNames(idx, []int{i})
+	}
+	return nil, nil
+}
+
+// LookupNamePrefix is like LookupName for all names starting with prefix.
+func (b *Binary) LookupNamePrefix(prefix string) ([]NameEntry, error) {
+	idx, err := b.nameIndex()
+	if err != nil {
+		return nil, err
+	}
+	var found []int
+	for i := sort.SearchStrings(idx.names, prefix); i < len(idx.names) && strings.HasPrefix(idx.names[i], prefix); i++ {
+		found = append(found, i)
+	}
+	return b.lookupNames(idx, found)
+}
+
+// LookupNameRegexp is like LookupName for all names matching re.
+func (b *Binary) LookupNameRegexp(re *regexp.Regexp) ([]NameEntry, error) {
+	idx, err := b.nameIndex()
+	if err != nil {
+		return nil, err
+	}
+	var found []int
+	for i, name := range idx.names {
+		if re.MatchString(name) {
+			found = append(found, i)
+		}
+	}
+	return b.lookupNames(idx, found)
+}
+
+func (b *Binary) lookupNames(idx *nameIndex, found []int) ([]NameEntry, error) {
+	var ents []NameEntry
+	for _, i := range found {
+		ents = append(ents, idx.entries[i]...)
+		for _, cu := range idx.cus[i] {
+			names, err := cu.getNames()
+			if err != nil {
+				return nil, err
+			}
+			for _, ent := range names {
+				if ent.Name == idx.names[i] {
+					ents = append(ents, ent)
+				}
+			}
+		}
+	}
+	// Split units and supplementary files are Binaries of their own, with
+	// offsets starting at 0.
+	rank := make(map[*Binary]int)
+	for _, ent := range ents {
+		if _, ok := rank[ent.CU.Binary]; !ok {
+			rank[ent.CU.Binary] = len(rank)
+		}
+	}
+	sort.SliceStable(ents, func(i, j int) bool {
+		if ri, rj := rank[ents[i].CU.Binary], rank[ents[j].CU.Binary]; ri != rj {
+			return ri < rj
+		}
+		return ents[i].Offset < ents[j].Offset
+	})
+	return compactNameEntries(ents), nil
+}
+
+func compactNameEntries(ents []NameEntry) []NameEntry {
+	var out []NameEntry
+	for _, ent := range ents {
+		if n := len(out); n > 0 && out[n-1].CU.Binary == ent.CU.Binary && out[n-1].Offset == ent.Offset {
+			continue
+		}
+		out = append(out, ent)
+	}
+	return out
+}
+
+// nameIndex returns the name index of b. Units not covered by .debug_names
+// or .gdb_index are walked, including partial and type units.
+func (b *Binary) nameIndex() (*nameIndex, error) {
+	b.mu.Lock()
+	idx := b.names
+	b.mu.Unlock()
+	if idx != nil {
+		return idx, nil
+	}
+	cus, err := b.allUnits()
+	if err != nil {
+		return nil, err
+	}
+	entries := make(map[string][]NameEntry)
+	nameCUs := make(map[string][]*DWARFCompileUnit)
+	covered, err := b.readDebugNames(cus, entries)
+	if err == nil && covered == nil {
+		covered, err = b.readGdbIndex(cus, nameCUs)
+	}
+	if err != nil {
+		// Fall back to walking all CUs.
+		clear(entries)
+		clear(nameCUs)
+		covered = nil
+	}
+	var walk []*DWARFCompileUnit
+	for _, cu := range cus {
+		if !covered[cu] {
+			walk = append(walk, cu)
+		}
+	}
+	results := make([][]NameEntry, len(walk))
+	err = b.forEachCompileUnit(context.Background(), walk, func(i int, cu *DWARFCompileUnit) error {
+		names, err := cu.getNames()
+		results[i] = names
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	for _, names := range results {
+		for _, ent := range names {
+			entries[ent.Name] = append(entries[ent.Name], ent)
+		}
+	}
+	idx = &nameIndex{}
+	for name := range entries {
+		idx.names = append(idx.names, name)
+	}
+	for name := range nameCUs {
+		if _, ok := entries[name]; !ok {
+			idx.names = append(idx.names, name)
+		}
+	}
+	sort.Strings(idx.names)
+	for _, name := range idx.names {
+		idx.entries = append(idx.entries, entries[name])
+		idx.cus = append(idx.cus, nameCUs[name])
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.names == nil {
+		b.names = idx
+	}
+	return b.names, nil
+}
+
+func indexedTag(tag dwarf.Tag) bool {
+	switch tag {
+	case dwarf.TagSubprogram, dwarf.TagInlinedSubroutine, dwarf.TagVariable,
+		dwarf.TagBaseType, dwarf.TagStructType, dwarf.TagClassType, dwarf.TagUnionType,
+		dwarf.TagEnumerationType, dwarf.TagTypedef:
+		return true
+	}
+	return false
+}
+
+// getNames walks the DIEs of cu, or of its split unit, for the entries of
+// the name index.
+func (cu *DWARFCompileUnit) getNames() ([]NameEntry, error) {
+	if split, err := cu.splitUnit(); err != nil || split != nil {
+		if err != nil {
+			return nil, err
+		}
+		return split.getNames()
+	}
+	k := fmt.Sprintf("%v", cu.Entry.Offset)
+	if e, ok := cu.Binary.namesCMap.Get(k); ok {
+		return e, nil
+	}
+	var names []NameEntry
+	// Tags of the DIEs enclosing the current one.
+	var scopes []dwarf.Tag
+	r := cu.Dwarf.Reader()
+	r.Seek(cu.Entry.Offset)
+	for {
+		ent, err := r.Next()
+		if err != nil {
+			return nil, err
+		}
+		if ent == nil {
+			break
+		}
+		if ent.Tag == 0 {
+			if len(scopes) <= 1 {
+				break
+			}
+			scopes = scopes[:len(scopes)-1]
+			continue
+		}
+		if len(scopes) != 0 && indexedTag(ent.Tag) {
+			scope := scopes[len(scopes)-1]
+			global := isUnit(scope) || scope == dwarf.TagNamespace
+			if (ent.Tag != dwarf.TagVariable || global) && ent.Val(dwarf.AttrDeclaration) == nil {
+				name, err := cu.entryName(ent)
+				if err != nil {
+					return nil, err
+				}
+				if name != "" {
+					names = append(names, NameEntry{
+						Name:   name,
+						Tag:    ent.Tag,
+						CU:     cu,
+						Offset: ent.Offset,
+					})
+				}
+			}
+		}
+		if ent.Children {
+			scopes = append(scopes, ent.Tag)
+		} else if len(scopes) == 0 {
+			break
+		}
+	}
+	cu.Binary.namesCMap.Set(k, names)
+	return names, nil
+}
+
+// entryName returns the name of ent, following DW_AT_abstract_origin and
+// DW_AT_specification into other units if needed.
+func (cu *DWARFCompileUnit) entryName(ent *dwarf.Entry) (string, error) {
+	for range 4 {
+		name, err := cu.Binary.stringAttr(ent, dwarf.AttrName)
+		if err != nil || name != "" {
+			return name, err
+		}
+		u, ref, err := cu.refEntry(ent, dwarf.AttrAbstractOrigin)
+		if err == nil && ref == nil {
+			u, ref, err = cu.refEntry(ent, dwarf.AttrSpecification)
+		}
+		if err != nil || ref == nil {
+			return "", err
+		}
+		cu, ent = u, ref
+	}
+	return "", nil
+}
+
+// DWARF 5 name index attributes and forms.
+const (
+	idxCompileUnit = 1
+	idxTypeUnit    = 2
+	idxDIEOffset   = 3
+
+	formData2       = 0x05
+	formData4       = 0x06
+	formData8       = 0x07
+	formData1       = 0x0b
+	formSdata       = 0x0d
+	formUdata       = 0x0f
+	formRef1        = 0x11
+	formRef2        = 0x12
+	formRef4        = 0x13
+	formRef8        = 0x14
+	formRefUdata    = 0x15
+	formFlagPresent = 0x19
+)
+
+var errBadNameIndex = errors.New("malformed name index")
+
+// nameReader decodes the little or big endian fields of an accelerator
+// section.
+type nameReader struct {
+	data  []byte
+	off   int
+	order binary.ByteOrder
+	err   error
+}
+
+func (r *nameReader) bytes(n int) []byte {
+	if r.err != nil || n < 0 || n > len(r.data)-r.off {
+		r.err = errBadNameIndex
+		if n > 8 {
+			return nil
+		}
+		return make([]byte, 8)
+	}
+	b := r.data[r.off : r.off+n]
+	r.off += n
+	return b
+}
+
+func (r *nameReader) uint(n int) uint64 {
+	b := r.bytes(n)
+	switch n {
+	case 1:
+		return uint64(b[0])
+	case 2:
+		return uint64(r.order.Uint16(b))
+	case 4:
+		return uint64(r.order.Uint32(b))
+	}
+	return r.order.Uint64(b)
+}
+
+func (r *nameReader) uleb() uint64 {
+	var v uint64
+	for shift := uint(0); ; shift += 7 {
+		c := r.bytes(1)[0]
+		if r.err != nil {
+			return 0
+		}
+		if shift < 64 {
+			v |= uint64(c&0x7f) << shift
+		}
+		if c&0x80 == 0 {
+			return v
+		}
+	}
+}
+
+func (r *nameReader) form(form uint64) uint64 {
+	switch form {
+	case formData1, formRef1:
+		return r.uint(1)
+	case formData2, formRef2:
+		return r.uint(2)
+	case formData4, formRef4:
+		return r.uint(4)
+	case formData8, formRef8:
+		return r.uint(8)
+	case formUdata, formRefUdata, formSdata:
+		return r.uleb()
+	case formFlagPresent:
+		return 1
+	}
+	r.err = fmt.Errorf("%w: unsupported form 0x%x", errBadNameIndex, form)
+	return 0
+}
+
+func (b *Binary) debugStr() ([]byte, error) {
+	db, f, err := b.debugFile()
+	if err != nil {
+		return nil, err
+	}
+	s := debugSection(f, ".debug_str")
+	if s == nil {
+		return nil, nil
+	}
+	return db.sectionData(s)
+}
+
+func strAt(strs []byte, off uint64) string {
+	if off > math.MaxUint32 {
+		return ""
+	}
+	return cString(strs, uint32(off))
+}
+
+// readDebugNames adds the entries of .debug_names to entries and returns
+// the CUs it covers, or nil if there is no .debug_names.
+func (b *Binary) readDebugNames(cus []*DWARFCompileUnit, entries map[string][]NameEntry) (map[*DWARFCompileUnit]bool, error) {
+	db, f, err := b.debugFile()
+	if err != nil {
+		return nil, err
+	}
+	s := debugSection(f, ".debug_names")
+	if s == nil || hasDebugRelocations(f) {
+		return nil, nil
+	}
+	data, err := db.sectionData(s)
+	if err != nil {
+		return nil, err
+	}
+	strs, err := b.debugStr()
+	if err != nil {
+		return nil, err
+	}
+	covered := make(map[*DWARFCompileUnit]bool)
+	for off := 0; off < len(data); {
+		r := &nameReader{data: data, off: off, order: f.ByteOrder}
+		length, offSize := r.uint(4), 4
+		if length == 0xffffffff {
+			length, offSize = r.uint(8), 8
+		}
+		if r.err != nil || length > uint64(len(data)-r.off) {
+			return nil, errBadNameIndex
+		}
+		end := r.off + int(length)
+		r.data = data[:end]
+		if version := r.uint(2); version != 5 {
+			return nil, fmt.Errorf("%w: .debug_names version %v", errBadNameIndex, version)
+		}
+		r.uint(2)
+		cuCount := int(r.uint(4))
+		localTUCount := int(r.uint(4))
+		foreignTUCount := int(r.uint(4))
+		bucketCount := int(r.uint(4))
+		nameCount := int(r.uint(4))
+		abbrevSize := int(r.uint(4))
+		r.bytes(int(r.uint(4)))
+		if r.err != nil || cuCount > (len(r.data)-r.off)/offSize {
+			return nil, errBadNameIndex
+		}
+		unitCUs := make([]*DWARFCompileUnit, cuCount)
+		unitOffs := make([]uint64, cuCount)
+		for i := range cuCount {
+			unitOffs[i] = r.uint(offSize)
+			unitCUs[i] = compileUnitAt(cus, unitOffs[i])
+			if unitCUs[i] != nil && unitCUs[i].isSkeleton() {
+				// DIE offsets are into the split unit, walk it instead.
+				unitCUs[i] = nil
+			}
+			if unitCUs[i] != nil {
+				covered[unitCUs[i]] = true
+			}
+		}
+		r.bytes(localTUCount * offSize)
+		r.bytes(foreignTUCount * 8)
+		r.bytes(bucketCount * 4)
+		if bucketCount != 0 {
+			r.bytes(nameCount * 4)
+		}
+		strOffs := r.off
+		r.bytes(nameCount * offSize)
+		entryOffs := r.off
+		r.bytes(nameCount * offSize)
+		abbrevs, err := readNameAbbrevs(&nameReader{data: r.bytes(abbrevSize), order: f.ByteOrder})
+		if err != nil {
+			return nil, err
+		}
+		if r.err != nil {
+			return nil, r.err
+		}
+		pool := r.off
+		for i := range nameCount {
+			r.off = strOffs + i*offSize
+			name := strAt(strs, r.uint(offSize))
+			r.off = entryOffs + i*offSize
+			r.off = pool + int(r.uint(offSize))
+			for r.err == nil {
+				code := r.uleb()
+				if code == 0 {
+					break
+				}
+				abbrev, ok := abbrevs[code]
+				if !ok {
+					return nil, fmt.Errorf("%w: unknown abbrev %v", errBadNameIndex, code)
+				}
+				cu, typeUnit := -1, false
+				var dieOff uint64
+				if cuCount == 1 {
+					cu = 0
+				}
+				for _, attr := range abbrev.attrs {
+					v := r.form(attr[1])
+					switch attr[0] {
+					case idxCompileUnit:
+						cu = int(v)
+					case idxTypeUnit:
+						typeUnit = true
+					case idxDIEOffset:
+						dieOff = v
+					}
+				}
+				if typeUnit || cu < 0 || cu >= cuCount || unitCUs[cu] == nil || !indexedTag(abbrev.tag) {
+					continue
+				}
+				entries[name] = append(entries[name], NameEntry{
+					Name:   name,
+					Tag:    abbrev.tag,
+					CU:     unitCUs[cu],
+					Offset: dwarf.Offset(unitOffs[cu] + dieOff),
+				})
+			}
+			if r.err != nil {
+				return nil, r.err
+			}
+		}
+		off = end
+	}
+	return covered, nil
+}
+
+type nameAbbrev struct {
+	tag   dwarf.Tag
+	attrs [][2]uint64
+}
+
+func readNameAbbrevs(r *nameReader) (map[uint64]nameAbbrev, error) {
+	abbrevs := make(map[uint64]nameAbbrev)
+	for r.err == nil {
+		code := r.uleb()
+		if code == 0 {
+			break
+		}
+		abbrev := nameAbbrev{tag: dwarf.Tag(r.uleb())}
+		for r.err == nil {
+			idx, form := r.uleb(), r.uleb()
+			if idx == 0 && form == 0 {
+				break
+			}
+			abbrev.attrs = append(abbrev.attrs, [2]uint64{idx, form})
+		}
+		abbrevs[code] = abbrev
+	}
+	return abbrevs, r.err
+}
+
+// readGdbIndex adds the CUs of each name in .gdb_index to nameCUs and
+// returns the CUs it covers, or nil if there is no .gdb_index. Qualified
+// C++ names are stored by their last component, like DW_AT_name.
+func (b *Binary) readGdbIndex(cus []*DWARFCompileUnit, nameCUs map[string][]*DWARFCompileUnit) (map[*DWARFCompileUnit]bool, error) {
+	db, f, err := b.debugFile()
+	if err != nil {
+		return nil, err
+	}
+	s := f.Section(".gdb_index")
+	if s == nil {
+		return nil, nil
+	}
+	data, err := db.sectionData(s)
+	if err != nil {
+		return nil, err
+	}
+	r := &nameReader{data: data, order: binary.LittleEndian}
+	version := r.uint(4)
+	if version < 7 || version > 9 {
+		return nil, fmt.Errorf("%w: .gdb_index version %v", errBadNameIndex, version)
+	}
+	cuList, typesList := int(r.uint(4)), int(r.uint(4))
+	r.uint(4)
+	symTab := int(r.uint(4))
+	if version >= 9 {
+		r.uint(4)
+	}
+	pool := int(r.uint(4))
+	if r.err != nil || cuList > typesList || typesList > len(data) || symTab > pool || pool > len(data) {
+		return nil, errBadNameIndex
+	}
+	covered := make(map[*DWARFCompileUnit]bool)
+	var unitCUs []*DWARFCompileUnit
+	for r.off = cuList; r.off+16 <= typesList && r.err == nil; {
+		cu := compileUnitAt(cus, r.uint(8))
+		r.uint(8)
+		unitCUs = append(unitCUs, cu)
+		if cu != nil {
+			covered[cu] = true
+		}
+	}
+	for r.off = symTab; r.off+8 <= pool && r.err == nil; {
+		nameOff, vecOff := r.uint(4), r.uint(4)
+		if nameOff == 0 && vecOff == 0 {
+			continue
+		}
+		if pool+int(nameOff) >= len(data) {
+			return nil, errBadNameIndex
+		}
+		name := cString(data[pool:], uint32(nameOff))
+		if i := strings.LastIndex(name, "::"); i >= 0 {
+			name = name[i+2:]
+		}
+		vec := &nameReader{data: data, off: pool + int(vecOff), order: binary.LittleEndian}
+		n := int(vec.uint(4))
+		for range n {
+			v := vec.uint(4)
+			if vec.err != nil {
+				return nil, vec.err
+			}
+			// The low 24 bits are the CU, types and static symbols included.
+			if cu := int(v & 0xffffff); cu < len(unitCUs) && unitCUs[cu] != nil {
+				nameCUs[name] = append(nameCUs[name], unitCUs[cu])
+			}
+		}
+	}
+	for name, cus := range nameCUs {
+		sort.Slice(cus, func(i, j int) bool {
+			return cus[i].Entry.Offset < cus[j].Entry.Offset
+		})
+		nameCUs[name] = compactCUs(cus)
+	}
+	return covered, r.err
+}
+
+func compactCUs(cus []*DWARFCompileUnit) []*DWARFCompileUnit {
+	var out []*DWARFCompileUnit
+	for _, cu := range cus {
+		if n := len(out); n > 0 && out[n-1] == cu {
+			continue
+		}
+		out = append(out, cu)
+	}
+	return out
+}
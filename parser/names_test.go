@@ -0,0 +1,112 @@
+// =============================================================================
+//  @@-COPYRIGHT-START-@@
+//
+//  Copyright (c) 2024, Qualcomm Innovation Center, Inc. All rights reserved.
+//
+//  Redistribution and use in source and binary forms, with or without
+//  modification, are permitted provided that the following conditions are met:
+//
+//  1. Redistributions of source code must retain the above copyright notice,
+//     this list of conditions and the following disclaimer.
+//
+//  2. Redistributions in binary form must reproduce the above copyright notice,
+//     this list of conditions and the following disclaimer in the documentation
+//     and/or other materials provided with the distribution.
+//
+//  3. Neither the name of the copyright holder nor the names of its contributors
+//     may be used to endorse or promote products derived from this software
+//     without specific prior written permission.
+//
+//  THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS"
+//  AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE
+//  IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE
+//  ARE DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT HOLDER OR CONTRIBUTORS BE
+//  LIABLE FOR ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR
+//  CONSEQUENTIAL DAMAGES (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF
+//  SUBSTITUTE GOODS OR SERVICES; LOSS OF USE, DATA, OR PROFITS; OR BUSINESS
+//  INTERRUPTION) HOWEVER CAUSED AND ON ANY THEORY OF LIABILITY, WHETHER IN
+//  CONTRACT, STRICT LIABILITY, OR TORT (INCLUDING NEGLIGENCE OR OTHERWISE)
+//  ARISING IN ANY WAY OUT OF THE USE OF THIS SOFTWARE, EVEN IF ADVISED OF THE
+//  POSSIBILITY OF SUCH DAMAGE.
+//
+//  SPDX-License-Identifier: BSD-3-Clause
+//
+//  @@-COPYRIGHT-END-@@
+// =============================================================================
+
+package dwarfparser
+
+import (
+	"debug/dwarf"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"testing"
+)
+
+// testdata/inl.gdbindex has a .gdb_index, the names of testdata/inl are
+// found by walking its units. In both, start and the abstract instance of
+// twice are in inl1.c, add and counter in inl2.c.
+func TestLookupName(t *testing.T) {
+	type nameEntry struct {
+		name string
+		tag  dwarf.Tag
+		cu   string
+	}
+	for _, file := range []string{"inl", "inl.gdbindex"} {
+		b, err := Open(filepath.Join("testdata", file))
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer b.Close()
+		cus, err := b.allUnits()
+		if err != nil {
+			t.Fatal(err)
+		}
+		covered, err := b.readGdbIndex(cus, make(map[string][]*DWARFCompileUnit))
+		if want := map[string]int{"inl.gdbindex": 2}[file]; err != nil || len(covered) != want {
+			t.Errorf("%v: .gdb_index covers %d units, %v, want %d", file, len(covered), err, want)
+		}
+		for _, tt := range []struct {
+			lookup string
+			find   func(string) ([]NameEntry, error)
+			arg    string
+			want   []nameEntry
+		}{
+			{"exact", b.LookupName, "add", []nameEntry{{"add", dwarf.TagSubprogram, "inl2.c"}}},
+			{"exact", b.LookupName, "counter", []nameEntry{{"counter", dwarf.TagVariable, "inl2.c"}}},
+			{"exact", b.LookupName, "point", []nameEntry{{"point", dwarf.TagStructType, "inl1.c"}}},
+			{"exact", b.LookupName, "twice", []nameEntry{
+				{"twice", dwarf.TagInlinedSubroutine, "inl1.c"},
+				{"twice", dwarf.TagSubprogram, "inl1.c"},
+			}},
+			{"exact", b.LookupName, "tw", nil},
+			{"prefix", b.LookupNamePrefix, "tw", []nameEntry{
+				{"twice", dwarf.TagInlinedSubroutine, "inl1.c"},
+				{"twice", dwarf.TagSubprogram, "inl1.c"},
+			}},
+			{"prefix", b.LookupNamePrefix, "s", []nameEntry{{"start", dwarf.TagSubprogram, "inl1.c"}}},
+			{"prefix", b.LookupNamePrefix, "x", nil},
+			{"regexp", func(expr string) ([]NameEntry, error) {
+				return b.LookupNameRegexp(regexp.MustCompile(expr))
+			}, "^(add|int|start)$", []nameEntry{
+				{"int", dwarf.TagBaseType, "inl1.c"},
+				{"start", dwarf.TagSubprogram, "inl1.c"},
+				{"int", dwarf.TagBaseType, "inl2.c"},
+				{"add", dwarf.TagSubprogram, "inl2.c"},
+			}},
+		} {
+			ents, err := tt.find(tt.arg)
+			if err != nil {
+				t.Fatalf("%v: %v lookup of %q: %v", file, tt.lookup, tt.arg, err)
+			}
+			var got []nameEntry
+			for _, ent := range ents {
+				got = append(got, nameEntry{ent.Name, ent.Tag, ent.CU.Name})
+			}
+			if !slices.Equal(got, tt.want) {
+				t.Errorf("%v: %v lookup of %q = %v, want %v", file, tt.lookup, tt.arg, got, tt.want)
+			}
+		}
+	}
+}
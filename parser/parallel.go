@@ -0,0 +1,105 @@
+// =============================================================================
+//  @@-COPYRIGHT-START-@@
+//
+//  Copyright (c) 2024, Qualcomm Innovation Center, Inc. All rights reserved.
+//
+//  Redistribution and use in source and binary forms, with or without
+//  modification, are permitted provided that the following conditions are met:
+//
+//  1. Redistributions of source code must retain the above copyright notice,
+//     this list of conditions and the following disclaimer.
+//
+//  2. Redistributions in binary form must reproduce the above copyright notice,
+//     this list of conditions and the following disclaimer in the documentation
+//     and/or other materials provided with the distribution.
+//
+//  3. Neither the name of the copyright holder nor the names of its contributors
+//     may be used to endorse or promote products derived from this software
+//     without specific prior written permission.
+//
+//  THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS"
+//  AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE
+//  IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE
+//  ARE DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT HOLDER OR CONTRIBUTORS BE
+//  LIABLE FOR ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR
+//  CONSEQUENTIAL DAMAGES (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF
+//  SUBSTITUTE GOODS OR SERVICES; LOSS OF USE, DATA, OR PROFITS; OR BUSINESS
+//  INTERRUPTION) HOWEVER CAUSED AND ON ANY THEORY OF LIABILITY, WHETHER IN
+//  CONTRACT, STRICT LIABILITY, OR TORT (INCLUDING NEGLIGENCE OR OTHERWISE)
+//  ARISING IN ANY WAY OUT OF THE USE OF THIS SOFTWARE, EVEN IF ADVISED OF THE
+//  POSSIBILITY OF SUCH DAMAGE.
+//
+//  SPDX-License-Identifier: BSD-3-Clause
+//
+//  @@-COPYRIGHT-END-@@
+// =============================================================================
+
+package dwarfparser
+
+import (
+	"context"
+	"runtime"
+	"sync"
+)
+
+// SetParallelism limits the number of goroutines used to index compile
+// units. n <= 0 means runtime.GOMAXPROCS(0), which is the default.
+func (b *Binary) SetParallelism(n int) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.parallelism = n
+}
+
+func (b *Binary) Parallelism() int {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.parallelism <= 0 {
+		return runtime.GOMAXPROCS(0)
+	}
+	return b.parallelism
+}
+
+// forEachCompileUnit calls fn for every compile unit of cus on a pool of
+// b.Parallelism() goroutines. It stops at the first error returned by fn or
+// once ctx is done. fn gets the index of cu in cus so that callers can keep
+// results in the order of cus regardless of which goroutine finishes first.
+func (b *Binary) forEachCompileUnit(ctx context.Context, cus []*DWARFCompileUnit, fn func(i int, cu *DWARFCompileUnit) error) error {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	workers := b.Parallelism()
+	if workers > len(cus) {
+		workers = len(cus)
+	}
+	idxC := make(chan int)
+	errC := make(chan error, workers)
+	var wg sync.WaitGroup
+	for w := 0; w < workers; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range idxC {
+				if err := fn(i, cus[i]); err != nil {
+					errC <- err
+					cancel()
+					return
+				}
+			}
+		}()
+	}
+loop:
+	for i := range cus {
+		select {
+		case idxC <- i:
+		case <-ctx.Done():
+			break loop
+		}
+	}
+	close(idxC)
+	wg.Wait()
+	select {
+	case err := <-errC:
+		return err
+	default:
+	}
+	return ctx.Err()
+}
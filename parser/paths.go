@@ -0,0 +1,148 @@
+// =============================================================================
+//  @@-COPYRIGHT-START-@@
+//
+//  Copyright (c) 2024, Qualcomm Innovation Center, Inc. All rights reserved.
+//
+//  Redistribution and use in source and binary forms, with or without
+//  modification, are permitted provided that the following conditions are met:
+//
+//  1. Redistributions of source code must retain the above copyright notice,
+//     this list of conditions and the following disclaimer.
+//
+//  2. Redistributions in binary form must reproduce the above copyright notice,
+//     this list of conditions and the following disclaimer in the documentation
+//     and/or other materials provided with the distribution.
+//
+//  3. Neither the name of the copyright holder nor the names of its contributors
+//     may be used to endorse or promote products derived from this software
+//     without specific prior written permission.
+//
+//  THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS"
+//  AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE
+//  IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE
+//  ARE DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT HOLDER OR CONTRIBUTORS BE
+//  LIABLE FOR ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR
+//  CONSEQUENTIAL DAMAGES (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF
+//  SUBSTITUTE GOODS OR SERVICES; LOSS OF USE, DATA, OR PROFITS; OR BUSINESS
+//  INTERRUPTION) HOWEVER CAUSED AND ON ANY THEORY OF LIABILITY, WHETHER IN
+//  CONTRACT, STRICT LIABILITY, OR TORT (INCLUDING NEGLIGENCE OR OTHERWISE)
+//  ARISING IN ANY WAY OUT OF THE USE OF THIS SOFTWARE, EVEN IF ADVISED OF THE
+//  POSSIBILITY OF SUCH DAMAGE.
+//
+//  SPDX-License-Identifier: BSD-3-Clause
+//
+//  @@-COPYRIGHT-END-@@
+// =============================================================================
+
+package dwarfparser
+
+import (
+	"path"
+	"slices"
+	"strings"
+	"sync"
+)
+
+// PrefixMap rewrites source paths starting with the directory From to
+// start with To instead, undoing -fdebug-prefix-map=To=From.
+type PrefixMap struct {
+	From string
+	To   string
+}
+
+var prefixMaps = struct {
+	sync.Mutex
+	maps []PrefixMap
+}{}
+
+// SetPrefixMaps sets the rules of the Binaries used by the path based
+// functions of this package, see Binary.SetPrefixMaps.
+func SetPrefixMaps(maps ...PrefixMap) {
+	prefixMaps.Lock()
+	prefixMaps.maps = slices.Clone(maps)
+	prefixMaps.Unlock()
+	binaries.each(func(b *Binary) {
+		b.SetPrefixMaps(maps...)
+	})
+}
+
+// PrefixMaps returns the rules set by SetPrefixMaps.
+func PrefixMaps() []PrefixMap {
+	prefixMaps.Lock()
+	defer prefixMaps.Unlock()
+	return slices.Clone(prefixMaps.maps)
+}
+
+// SetPrefixMaps sets the rules applied to the source paths of the frames
+// returned by b. The first rule matching a path wins.
+func (b *Binary) SetPrefixMaps(maps ...PrefixMap) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.prefixMaps = slices.Clone(maps)
+}
+
+func (b *Binary) PrefixMaps() []PrefixMap {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return slices.Clone(b.prefixMaps)
+}
+
+// SetPrefixMaps is Binary.SetPrefixMaps for the frames returned by idx.
+// An index starts with the rules of the Binary it was loaded for.
+func (idx *Index) SetPrefixMaps(maps ...PrefixMap) {
+	idx.mu.Lock()
+	defer idx.mu.Unlock()
+	idx.prefixMaps = slices.Clone(maps)
+}
+
+func (idx *Index) PrefixMaps() []PrefixMap {
+	idx.mu.Lock()
+	defer idx.mu.Unlock()
+	return slices.Clone(idx.prefixMaps)
+}
+
+func remapPath(maps []PrefixMap, name string) string {
+	for _, m := range maps {
+		from := strings.TrimSuffix(m.From, "/")
+		rest, ok := strings.CutPrefix(name, from)
+		if from == "" || !ok || (rest != "" && rest[0] != '/') {
+			continue
+		}
+		if m.To == "" {
+			return strings.TrimPrefix(rest, "/")
+		}
+		return strings.TrimSuffix(m.To, "/") + rest
+	}
+	return name
+}
+
+// remapFrames applies maps to the paths of frames.
+func remapFrames(maps []PrefixMap, frames []Frame) {
+	if len(maps) == 0 {
+		return
+	}
+	for i := range frames {
+		f := &frames[i]
+		f.File = remapPath(maps, f.File)
+		f.StartFile = remapPath(maps, f.StartFile)
+		f.CompDir = remapPath(maps, f.CompDir)
+	}
+}
+
+// resolvePath returns name, a file of the line table of cu, made absolute
+// with the compilation directory of cu, with . and .. elements removed.
+func (cu *DWARFCompileUnit) resolvePath(name string) string {
+	if name == "" || name == "??" {
+		return name
+	}
+	if path.IsAbs(name) || cu.CompDir == "" {
+		return path.Clean(name)
+	}
+	// debug/dwarf already joined a relative compilation directory, except
+	// to DWARF 5 include directories.
+	dir := path.Clean(cu.CompDir)
+	if !path.IsAbs(dir) && (dir == "." || strings.HasPrefix(path.Clean(name)+"/", dir+"/")) {
+		return path.Clean(name)
+	}
+	return path.Join(dir, name)
+}
@@ -0,0 +1,120 @@
+// =============================================================================
+//  @@-COPYRIGHT-START-@@
+//
+//  Copyright (c) 2024, Qualcomm Innovation Center, Inc. All rights reserved.
+//
+//  Redistribution and use in source and binary forms, with or without
+//  modification, are permitted provided that the following conditions are met:
+//
+//  1. Redistributions of source code must retain the above copyright notice,
+//     this list of conditions and the following disclaimer.
+//
+//  2. Redistributions in binary form must reproduce the above copyright notice,
+//     this list of conditions and the following disclaimer in the documentation
+//     and/or other materials provided with the distribution.
+//
+//  3. Neither the name of the copyright holder nor the names of its contributors
+//     may be used to endorse or promote products derived from this software
+//     without specific prior written permission.
+//
+//  THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS"
+//  AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE
+//  IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE
+//  ARE DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT HOLDER OR CONTRIBUTORS BE
+//  LIABLE FOR ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR
+//  CONSEQUENTIAL DAMAGES (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF
+//  SUBSTITUTE GOODS OR SERVICES; LOSS OF USE, DATA, OR PROFITS; OR BUSINESS
+//  INTERRUPTION) HOWEVER CAUSED AND ON ANY THEORY OF LIABILITY, WHETHER IN
+//  CONTRACT, STRICT LIABILITY, OR TORT (INCLUDING NEGLIGENCE OR OTHERWISE)
+//  ARISING IN ANY WAY OUT OF THE USE OF THIS SOFTWARE, EVEN IF ADVISED OF THE
+//  POSSIBILITY OF SUCH DAMAGE.
+//
+//  SPDX-License-Identifier: BSD-3-Clause
+//
+//  @@-COPYRIGHT-END-@@
+// =============================================================================
+
+package dwarfparser
+
+import (
+	"context"
+	"path/filepath"
+	"testing"
+)
+
+func TestRemapPath(t *testing.T) {
+	for _, tt := range []struct {
+		maps []PrefixMap
+		name string
+		want string
+	}{
+		{nil, "/build/a.c", "/build/a.c"},
+		{[]PrefixMap{{"/build", "/src"}}, "/build/a.c", "/src/a.c"},
+		{[]PrefixMap{{"/build/", "/src/"}}, "/build/a.c", "/src/a.c"},
+		{[]PrefixMap{{"/build", "/src"}}, "/build", "/src"},
+		{[]PrefixMap{{"/build", "/src"}}, "/builds/a.c", "/builds/a.c"},
+		{[]PrefixMap{{"/build", "/src"}}, "a.c", "a.c"},
+		{[]PrefixMap{{"/build", ""}}, "/build/lib/a.c", "lib/a.c"},
+		{[]PrefixMap{{"", "/src"}}, "/build/a.c", "/build/a.c"},
+		{[]PrefixMap{{"/build/lib", "/lib"}, {"/build", "/src"}}, "/build/lib/a.c", "/lib/a.c"},
+		{[]PrefixMap{{"/build", "/src"}, {"/build/lib", "/lib"}}, "/build/lib/a.c", "/src/lib/a.c"},
+	} {
+		if got := remapPath(tt.maps, tt.name); got != tt.want {
+			t.Errorf("remapPath(%v, %q) = %q, want %q", tt.maps, tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestResolvePath(t *testing.T) {
+	for _, tt := range []struct {
+		compDir string
+		name    string
+		want    string
+	}{
+		{"/src", "", ""},
+		{"/src", "??", "??"},
+		{"/src", "a.c", "/src/a.c"},
+		{"/src/", "./a.c", "/src/a.c"},
+		{"/src", "lib/../a.c", "/src/a.c"},
+		{"/src/lib", "../a.c", "/src/a.c"},
+		{"/src", "/usr/include/../include/stdio.h", "/usr/include/stdio.h"},
+		{"", "lib/../a.c", "a.c"},
+		{".", "./lib/a.c", "lib/a.c"},
+		// debug/dwarf joined the relative directory already.
+		{"build", "build/a.c", "build/a.c"},
+		{"build", "include/a.h", "build/include/a.h"},
+		{"./build/", "build/../build/a.c", "build/a.c"},
+	} {
+		cu := &DWARFCompileUnit{CompDir: tt.compDir}
+		if got := cu.resolvePath(tt.name); got != tt.want {
+			t.Errorf("resolvePath(%q) in %q = %q, want %q", tt.name, tt.compDir, got, tt.want)
+		}
+	}
+}
+
+// The compilation directory of testdata/reloc/x86_64.o is /src/dwarfparser.
+func TestSetPrefixMaps(t *testing.T) {
+	b, err := Open(filepath.Join("testdata", "reloc", "x86_64.o"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer b.Close()
+	idx, err := b.BuildIndex(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer idx.Close()
+	maps := []PrefixMap{{"/src", "/home/user/src"}}
+	b.SetPrefixMaps(maps...)
+	idx.SetPrefixMaps(maps...)
+	for _, p := range []Provider{b, idx} {
+		frames, err := p.Addr2line(0x10)
+		if err != nil {
+			t.Fatal(err)
+		}
+		f := frames[0]
+		if f.File != "/home/user/src/dwarfparser/n.c" || f.StartFile != f.File || f.CompDir != "/home/user/src/dwarfparser" {
+			t.Errorf("%T.Addr2line(0x10) in %v, declared in %v, compiled in %v", p, f.File, f.StartFile, f.CompDir)
+		}
+	}
+}
@@ -0,0 +1,147 @@
+// =============================================================================
+//  @@-COPYRIGHT-START-@@
+//
+//  Copyright (c) 2024, Qualcomm Innovation Center, Inc. All rights reserved.
+//
+//  Redistribution and use in source and binary forms, with or without
+//  modification, are permitted provided that the following conditions are met:
+//
+//  1. Redistributions of source code must retain the above copyright notice,
+//     this list of conditions and the following disclaimer.
+//
+//  2. Redistributions in binary form must reproduce the above copyright notice,
+//     this list of conditions and the following disclaimer in the documentation
+//     and/or other materials provided with the distribution.
+//
+//  3. Neither the name of the copyright holder nor the names of its contributors
+//     may be used to endorse or promote products derived from this software
+//     without specific prior written permission.
+//
+//  THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS"
+//  AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE
+//  IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE
+//  ARE DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT HOLDER OR CONTRIBUTORS BE
+//  LIABLE FOR ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR
+//  CONSEQUENTIAL DAMAGES (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF
+//  SUBSTITUTE GOODS OR SERVICES; LOSS OF USE, DATA, OR PROFITS; OR BUSINESS
+//  INTERRUPTION) HOWEVER CAUSED AND ON ANY THEORY OF LIABILITY, WHETHER IN
+//  CONTRACT, STRICT LIABILITY, OR TORT (INCLUDING NEGLIGENCE OR OTHERWISE)
+//  ARISING IN ANY WAY OUT OF THE USE OF THIS SOFTWARE, EVEN IF ADVISED OF THE
+//  POSSIBILITY OF SUCH DAMAGE.
+//
+//  SPDX-License-Identifier: BSD-3-Clause
+//
+//  @@-COPYRIGHT-END-@@
+// =============================================================================
+
+package dwarfparser
+
+import (
+	"errors"
+	"iter"
+)
+
+var ErrNotSupported = errors.New("not supported by provider")
+
+var (
+	_ Provider = (*Binary)(nil)
+	_ Provider = (*SymbolTable)(nil)
+	_ Provider = (*LLVMAddr2line)(nil)
+	_ Provider = (*Index)(nil)
+	_ Provider = Chain(nil)
+)
+
+// Provider is a source of debug information for one binary.
+// Binary (DWARF), SymbolTable (ELF .symtab) and LLVMAddr2line
+// (llvm-addr2line subprocess) implement it, Chain combines them.
+type Provider interface {
+	// Addr2line returns the frames of pc, innermost first, see
+	// Binary.Addr2line for partial results on error.
+	Addr2line(pc uint64) ([]Frame, error)
+	FuncsByName(name string) ([]*DWARFFunction, error)
+	Funcs() iter.Seq2[*DWARFFunction, error]
+	Close() error
+}
+
+// Chain is a Provider asking each of its providers in order until one
+// succeeds.
+type Chain []Provider
+
+func NewChain(providers ...Provider) Chain {
+	return Chain(providers)
+}
+
+// Addr2line returns the frames of the first provider which resolves pc
+// without error. If none does, the partial frames of the first provider are
+// returned with the errors of all providers.
+func (c Chain) Addr2line(pc uint64) ([]Frame, error) {
+	var first []Frame
+	var errs []error
+	for i, p := range c {
+		frames, err := p.Addr2line(pc)
+		if err == nil {
+			return frames, nil
+		}
+		if i == 0 {
+			first = frames
+		}
+		errs = append(errs, err)
+	}
+	if len(c) == 0 {
+		return []Frame{unknownFrame(pc)}, ErrNotSupported
+	}
+	return first, errors.Join(errs...)
+}
+
+func (c Chain) FuncsByName(name string) ([]*DWARFFunction, error) {
+	var errs []error
+	for _, p := range c {
+		funcs, err := p.FuncsByName(name)
+		if err == nil && len(funcs) != 0 {
+			return funcs, nil
+		}
+		if err != nil && !errors.Is(err, ErrNotSupported) {
+			errs = append(errs, err)
+		}
+	}
+	return nil, errors.Join(errs...)
+}
+
+// Funcs walks the functions of the first provider whose walk doesn't
+// start with an error. If all of them fail, their errors are yielded.
+func (c Chain) Funcs() iter.Seq2[*DWARFFunction, error] {
+	return func(yield func(*DWARFFunction, error) bool) {
+		var errs []error
+		for _, p := range c {
+			started := false
+			for f, err := range p.Funcs() {
+				if !started && err != nil {
+					if !errors.Is(err, ErrNotSupported) {
+						errs = append(errs, err)
+					}
+					break
+				}
+				started = true
+				if !yield(f, err) {
+					return
+				}
+			}
+			if started {
+				return
+			}
+		}
+		if err := errors.Join(errs...); err != nil {
+			yield(nil, err)
+		}
+	}
+}
+
+func (c Chain) Close() error {
+	var errs []error
+	for _, p := range c {
+		if err := p.Close(); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return errors.Join(errs...)
+}
@@ -0,0 +1,70 @@
+// =============================================================================
+//  @@-COPYRIGHT-START-@@
+//
+//  Copyright (c) 2024, Qualcomm Innovation Center, Inc. All rights reserved.
+//
+//  Redistribution and use in source and binary forms, with or without
+//  modification, are permitted provided that the following conditions are met:
+//
+//  1. Redistributions of source code must retain the above copyright notice,
+//     this list of conditions and the following disclaimer.
+//
+//  2. Redistributions in binary form must reproduce the above copyright notice,
+//     this list of conditions and the following disclaimer in the documentation
+//     and/or other materials provided with the distribution.
+//
+//  3. Neither the name of the copyright holder nor the names of its contributors
+//     may be used to endorse or promote products derived from this software
+//     without specific prior written permission.
+//
+//  THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS"
+//  AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE
+//  IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE
+//  ARE DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT HOLDER OR CONTRIBUTORS BE
+//  LIABLE FOR ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR
+//  CONSEQUENTIAL DAMAGES (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF
+//  SUBSTITUTE GOODS OR SERVICES; LOSS OF USE, DATA, OR PROFITS; OR BUSINESS
+//  INTERRUPTION) HOWEVER CAUSED AND ON ANY THEORY OF LIABILITY, WHETHER IN
+//  CONTRACT, STRICT LIABILITY, OR TORT (INCLUDING NEGLIGENCE OR OTHERWISE)
+//  ARISING IN ANY WAY OUT OF THE USE OF THIS SOFTWARE, EVEN IF ADVISED OF THE
+//  POSSIBILITY OF SUCH DAMAGE.
+//
+//  SPDX-License-Identifier: BSD-3-Clause
+//
+//  @@-COPYRIGHT-END-@@
+// =============================================================================
+
+package dwarfparser
+
+import (
+	"path/filepath"
+	"slices"
+	"testing"
+)
+
+// testdata/stripped is testdata/split without debug sections.
+func TestChainFuncsFallsBack(t *testing.T) {
+	b, err := Open(filepath.Join("testdata", "stripped"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer b.Close()
+	st, err := NewSymbolTable(b)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got []string
+	for fn, err := range NewChain(b, st).Funcs() {
+		if err != nil {
+			t.Fatal(err)
+		}
+		got = append(got, fn.Name)
+	}
+	slices.Sort(got)
+	if want := []string{"f1", "f2", "helper", "helper"}; !slices.Equal(got, want) {
+		t.Errorf("Funcs() = %q, want %q", got, want)
+	}
+	for fn, err := range NewChain().Funcs() {
+		t.Errorf("Funcs() of an empty chain yields %v, %v", fn, err)
+	}
+}
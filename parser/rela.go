@@ -37,6 +37,7 @@
 package dwarfparser
 
 import (
+	"bytes"
 	"debug/elf"
 	"encoding/binary"
 	"fmt"
@@ -44,11 +45,19 @@ import (
 )
 
 func IsSectionExist(path, sec string) bool {
-	f, err := elf.Open(path)
+	b, release, err := openCached(path)
+	if err != nil {
+		return false
+	}
+	defer release()
+	return b.IsSectionExist(sec)
+}
+
+func (b *Binary) IsSectionExist(sec string) bool {
+	f, err := b.File()
 	if err != nil {
 		return false
 	}
-	defer f.Close()
 	s, err := GetSectionByName(f, sec)
 	if err != nil {
 		return false
@@ -57,51 +66,82 @@ func IsSectionExist(path, sec string) bool {
 }
 
 func FindAllCoverPointsInRelaSec(path string) ([2][]uint64, error) {
+	b, release, err := openCached(path)
+	if err != nil {
+		return [2][]uint64{}, err
+	}
+	defer release()
+	return b.FindAllCoverPointsInRelaSec()
+}
+
+// FindAllCoverPointsInRelaSec returns the calls to __sanitizer_cov_trace_pc
+// and to the other __sanitizer_cov_trace_ functions found in the relocations
+// of all executable sections, not only .text but also e.g. .init.text and
+// .exit.text of kernel modules.
+func (b *Binary) FindAllCoverPointsInRelaSec() ([2][]uint64, error) {
 	var pcs [2][]uint64
-	info, err := GetTracePCInfo(path)
+	info, err := b.GetTracePCInfo()
 	if err != nil {
 		return pcs, err
 	}
-	f, err := elf.Open(path)
+	f, err := b.File()
 	if err != nil {
 		return pcs, err
 	}
-	defer f.Close()
-	s, err := GetSectionByName(f, ".rela.text")
+	bases, err := b.sectionBases()
 	if err != nil {
 		return pcs, err
 	}
-	if s == nil {
-		return pcs, fmt.Errorf("no .rela.text section")
-	}
 	callRelocType := arches[f.FileHeader.Machine].callRelocType
 	relaOffset := arches[f.FileHeader.Machine].relaOffset
-	di, err := DWARF(path)
+	di, err := b.DWARF()
 	if err != nil {
 		return pcs, err
 	}
-	rel := new(elf.Rela64)
-	for r := s.Open(); ; {
-		if err := binary.Read(r, di.Reader().ByteOrder(), rel); err != nil {
-			if err == io.EOF {
-				break
-			}
-			return pcs, err
-		}
-		if (rel.Info & 0xffffffff) != callRelocType {
+	// Calls in .text, .init.text, .text.unlikely etc. of a relocatable
+	// object are told apart by the addresses from sectionBases. Elsewhere
+	// r_offset already is an address.
+	found := false
+	for _, s := range f.Sections {
+		if s.Type != elf.SHT_RELA || int(s.Info) >= len(f.Sections) ||
+			f.Sections[s.Info].Flags&elf.SHF_EXECINSTR == 0 {
 			continue
 		}
-		pc := rel.Off - relaOffset
-		index := int(elf.R_SYM64(rel.Info)) - 1
-		if info != nil {
-			if info.tracePCIdx[index] {
+		found = true
+		var base uint64
+		if f.Type == elf.ET_REL {
+			base = bases[s.Info]
+		}
+		data, err := b.sectionData(s)
+		if err != nil {
+			return pcs, err
+		}
+		rel := new(elf.Rela64)
+		for r := bytes.NewReader(data); ; {
+			if err := binary.Read(r, di.Reader().ByteOrder(), rel); err != nil {
+				if err == io.EOF {
+					break
+				}
+				return pcs, err
+			}
+			if (rel.Info & 0xffffffff) != callRelocType {
+				continue
+			}
+			pc := base + rel.Off - relaOffset
+			index := int(elf.R_SYM64(rel.Info)) - 1
+			if info != nil {
+				if info.tracePCIdx[index] {
+					pcs[0] = append(pcs[0], pc)
+				} else if info.traceCmpIdx[index] {
+					pcs[1] = append(pcs[1], pc)
+				}
+			} else {
 				pcs[0] = append(pcs[0], pc)
-			} else if info.traceCmpIdx[index] {
-				pcs[1] = append(pcs[1], pc)
 			}
-		} else {
-			pcs[0] = append(pcs[0], pc)
 		}
 	}
-	return pcs, err
+	if !found {
+		return pcs, fmt.Errorf("no .rela.text section")
+	}
+	return pcs, nil
 }
@@ -0,0 +1,408 @@
+// =============================================================================
+//  @@-COPYRIGHT-START-@@
+//
+//  Copyright (c) 2024, Qualcomm Innovation Center, Inc. All rights reserved.
+//
+//  Redistribution and use in source and binary forms, with or without
+//  modification, are permitted provided that the following conditions are met:
+//
+//  1. Redistributions of source code must retain the above copyright notice,
+//     this list of conditions and the following disclaimer.
+//
+//  2. Redistributions in binary form must reproduce the above copyright notice,
+//     this list of conditions and the following disclaimer in the documentation
+//     and/or other materials provided with the distribution.
+//
+//  3. Neither the name of the copyright holder nor the names of its contributors
+//     may be used to endorse or promote products derived from this software
+//     without specific prior written permission.
+//
+//  THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS"
+//  AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE
+//  IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE
+//  ARE DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT HOLDER OR CONTRIBUTORS BE
+//  LIABLE FOR ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR
+//  CONSEQUENTIAL DAMAGES (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF
+//  SUBSTITUTE GOODS OR SERVICES; LOSS OF USE, DATA, OR PROFITS; OR BUSINESS
+//  INTERRUPTION) HOWEVER CAUSED AND ON ANY THEORY OF LIABILITY, WHETHER IN
+//  CONTRACT, STRICT LIABILITY, OR TORT (INCLUDING NEGLIGENCE OR OTHERWISE)
+//  ARISING IN ANY WAY OUT OF THE USE OF THIS SOFTWARE, EVEN IF ADVISED OF THE
+//  POSSIBILITY OF SUCH DAMAGE.
+//
+//  SPDX-License-Identifier: BSD-3-Clause
+//
+//  @@-COPYRIGHT-END-@@
+// =============================================================================
+
+package dwarfparser
+
+import (
+	"debug/elf"
+	"encoding/binary"
+	"fmt"
+	"slices"
+	"sort"
+)
+
+// Relocation types missing in debug/elf.
+const (
+	rRISCVSetULEB128 = 60
+	rRISCVSubULEB128 = 61
+
+	rHexNone = 0
+	rHex32   = 6
+	rHex16   = 7
+	rHex8    = 8
+)
+
+// reloc is one relocation being applied to data.
+type reloc struct {
+	data  []byte
+	order binary.ByteOrder
+	off   uint64
+	typ   uint32
+	// s is the address of the symbol, see sectionBases, and tls its value
+	// within its section.
+	s   uint64
+	tls uint64
+	a   uint64
+	// rel is set for SHT_REL, where the addend is stored at off.
+	rel bool
+}
+
+func (r *reloc) fits(size uint64) bool {
+	return r.off+size >= r.off && r.off+size <= uint64(len(r.data))
+}
+
+func (r *reloc) get(size uint64) uint64 {
+	switch size {
+	case 1:
+		return uint64(r.data[r.off])
+	case 2:
+		return uint64(r.order.Uint16(r.data[r.off:]))
+	case 4:
+		return uint64(r.order.Uint32(r.data[r.off:]))
+	}
+	return r.order.Uint64(r.data[r.off:])
+}
+
+func (r *reloc) put(size, val uint64) bool {
+	if !r.fits(size) {
+		return false
+	}
+	switch size {
+	case 1:
+		r.data[r.off] = byte(val)
+	case 2:
+		r.order.PutUint16(r.data[r.off:], uint16(val))
+	case 4:
+		r.order.PutUint32(r.data[r.off:], uint32(val))
+	default:
+		r.order.PutUint64(r.data[r.off:], val)
+	}
+	return true
+}
+
+// addend returns the addend, read from the relocated field for SHT_REL.
+func (r *reloc) addend(size uint64) uint64 {
+	if r.rel && r.fits(size) {
+		return r.get(size)
+	}
+	return r.a
+}
+
+// abs stores S + A.
+func (r *reloc) abs(size uint64) bool {
+	return r.put(size, r.s+r.addend(size))
+}
+
+// dtprel stores the offset of the symbol within its TLS section.
+func (r *reloc) dtprel(size uint64) bool {
+	return r.put(size, r.tls+r.addend(size))
+}
+
+// add adds S + A to the field, sub subtracts it.
+func (r *reloc) add(size uint64) bool {
+	return r.fits(size) && r.put(size, r.get(size)+r.s+r.a)
+}
+
+func (r *reloc) sub(size uint64) bool {
+	return r.fits(size) && r.put(size, r.get(size)-r.s-r.a)
+}
+
+// uleb sets or subtracts S + A on the ULEB128 at off, keeping its length.
+func (r *reloc) uleb(sub bool) bool {
+	var v uint64
+	n := 0
+	for shift := uint(0); ; shift += 7 {
+		if r.off+uint64(n) >= uint64(len(r.data)) {
+			return false
+		}
+		c := r.data[r.off+uint64(n)]
+		n++
+		if shift < 64 {
+			v |= uint64(c&0x7f) << shift
+		}
+		if c&0x80 == 0 {
+			break
+		}
+	}
+	if sub {
+		v -= r.s + r.a
+	} else {
+		v = r.s + r.a
+	}
+	for i := range n {
+		c := byte(v & 0x7f)
+		v >>= 7
+		if i < n-1 {
+			c |= 0x80
+		}
+		r.data[r.off+uint64(i)] = c
+	}
+	return true
+}
+
+// debugRelocs apply the relocation types found in debug sections. They
+// report whether the type is supported.
+var debugRelocs = map[elf.Machine]func(r *reloc) bool{
+	elf.EM_X86_64: func(r *reloc) bool {
+		switch elf.R_X86_64(r.typ) {
+		case elf.R_X86_64_NONE:
+			return true
+		case elf.R_X86_64_64:
+			return r.abs(8)
+		case elf.R_X86_64_32, elf.R_X86_64_32S:
+			return r.abs(4)
+		case elf.R_X86_64_DTPOFF64:
+			return r.dtprel(8)
+		case elf.R_X86_64_DTPOFF32:
+			return r.dtprel(4)
+		}
+		return false
+	},
+	elf.EM_AARCH64: func(r *reloc) bool {
+		switch elf.R_AARCH64(r.typ) {
+		case elf.R_AARCH64_NONE, elf.R_AARCH64_NULL:
+			return true
+		case elf.R_AARCH64_ABS64:
+			return r.abs(8)
+		case elf.R_AARCH64_ABS32:
+			return r.abs(4)
+		case elf.R_AARCH64_ABS16:
+			return r.abs(2)
+		case elf.R_AARCH64_TLS_DTPREL64:
+			return r.dtprel(8)
+		}
+		return false
+	},
+	elf.EM_ARM: func(r *reloc) bool {
+		switch elf.R_ARM(r.typ) {
+		case elf.R_ARM_NONE:
+			return true
+		case elf.R_ARM_ABS32, elf.R_ARM_TARGET1:
+			return r.abs(4)
+		case elf.R_ARM_TLS_LDO32:
+			return r.dtprel(4)
+		}
+		return false
+	},
+	elf.EM_RISCV: func(r *reloc) bool {
+		switch elf.R_RISCV(r.typ) {
+		case elf.R_RISCV_NONE:
+			return true
+		case elf.R_RISCV_64:
+			return r.abs(8)
+		case elf.R_RISCV_32:
+			return r.abs(4)
+		case elf.R_RISCV_TLS_DTPREL64:
+			return r.dtprel(8)
+		case elf.R_RISCV_TLS_DTPREL32:
+			return r.dtprel(4)
+		case elf.R_RISCV_ADD8:
+			return r.add(1)
+		case elf.R_RISCV_ADD16:
+			return r.add(2)
+		case elf.R_RISCV_ADD32:
+			return r.add(4)
+		case elf.R_RISCV_ADD64:
+			return r.add(8)
+		case elf.R_RISCV_SUB8:
+			return r.sub(1)
+		case elf.R_RISCV_SUB16:
+			return r.sub(2)
+		case elf.R_RISCV_SUB32:
+			return r.sub(4)
+		case elf.R_RISCV_SUB64:
+			return r.sub(8)
+		case elf.R_RISCV_SET6:
+			return r.fits(1) && r.put(1, r.get(1)&0xc0|(r.s+r.a)&0x3f)
+		case elf.R_RISCV_SUB6:
+			return r.fits(1) && r.put(1, r.get(1)&0xc0|(r.get(1)-r.s-r.a)&0x3f)
+		case elf.R_RISCV_SET8:
+			return r.abs(1)
+		case elf.R_RISCV_SET16:
+			return r.abs(2)
+		case elf.R_RISCV_SET32:
+			return r.abs(4)
+		case rRISCVSetULEB128:
+			return r.uleb(false)
+		case rRISCVSubULEB128:
+			return r.uleb(true)
+		}
+		return false
+	},
+	elf.EM_QDSP6: func(r *reloc) bool {
+		switch r.typ {
+		case rHexNone:
+			return true
+		case rHex32:
+			return r.abs(4)
+		case rHex16:
+			return r.abs(2)
+		case rHex8:
+			return r.abs(1)
+		}
+		return false
+	},
+}
+
+// SkippedRelocation counts the relocations of one type in one section that
+// were not applied, because the type isn't supported or is out of range.
+type SkippedRelocation struct {
+	Section string
+	Type    string
+	Count   int
+}
+
+func SkippedRelocations(path string) ([]SkippedRelocation, error) {
+	b, release, err := openCached(path)
+	if err != nil {
+		return nil, err
+	}
+	defer release()
+	return b.SkippedRelocations()
+}
+
+// SkippedRelocations reports the relocations of debug sections that
+// couldn't be applied when loading the DWARF of a relocatable object.
+func (b *Binary) SkippedRelocations() ([]SkippedRelocation, error) {
+	if _, err := b.DWARF(); err != nil {
+		return nil, err
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return slices.Clone(b.skippedRelocs), nil
+}
+
+func relocTypeName(m elf.Machine, typ uint32) string {
+	switch m {
+	case elf.EM_X86_64:
+		return elf.R_X86_64(typ).String()
+	case elf.EM_AARCH64:
+		return elf.R_AARCH64(typ).String()
+	case elf.EM_ARM:
+		return elf.R_ARM(typ).String()
+	case elf.EM_RISCV:
+		return elf.R_RISCV(typ).String()
+	}
+	return fmt.Sprintf("%v type %v", m, typ)
+}
+
+// canRelocate reports whether the debug sections of f are relocated by
+// relocateSection instead of elf.File.DWARF.
+func canRelocate(f *elf.File) bool {
+	return f.Type == elf.ET_REL && debugRelocs[f.Machine] != nil
+}
+
+// relocateSection applies the REL and RELA sections targeting section idx to
+// data, which is copied first. Symbols in allocated sections are placed at
+// the addresses given by sectionBases. Relocations that can't be applied
+// are added to skipped.
+func (b *Binary) relocateSection(f *elf.File, idx int, data []byte, symbols []elf.Symbol, bases []uint64, skipped map[[2]string]int) ([]byte, error) {
+	apply := debugRelocs[f.Machine]
+	copied := false
+	for _, s := range f.Sections {
+		if (s.Type != elf.SHT_RELA && s.Type != elf.SHT_REL) || int(s.Info) != idx {
+			continue
+		}
+		rdata, err := b.sectionData(s)
+		if err != nil {
+			return nil, err
+		}
+		if !copied {
+			data = slices.Clone(data)
+			copied = true
+		}
+		size := 8
+		if f.Class == elf.ELFCLASS64 {
+			size = 16
+		}
+		if s.Type == elf.SHT_RELA {
+			size += size / 2
+		}
+		for i := 0; i+size <= len(rdata); i += size {
+			r := &reloc{
+				data:  data,
+				order: f.ByteOrder,
+				rel:   s.Type == elf.SHT_REL,
+			}
+			var sym uint32
+			if f.Class == elf.ELFCLASS64 {
+				r.off = f.ByteOrder.Uint64(rdata[i:])
+				info := f.ByteOrder.Uint64(rdata[i+8:])
+				sym, r.typ = elf.R_SYM64(info), elf.R_TYPE64(info)
+				if !r.rel {
+					r.a = f.ByteOrder.Uint64(rdata[i+16:])
+				}
+			} else {
+				r.off = uint64(f.ByteOrder.Uint32(rdata[i:]))
+				info := f.ByteOrder.Uint32(rdata[i+4:])
+				sym, r.typ = elf.R_SYM32(info), elf.R_TYPE32(info)
+				if !r.rel {
+					r.a = uint64(int64(int32(f.ByteOrder.Uint32(rdata[i+8:]))))
+				}
+			}
+			r.s, r.tls, err = symbolValue(symbols, bases, sym)
+			if err != nil {
+				return nil, fmt.Errorf("%v: %w", s.Name, err)
+			}
+			if !apply(r) {
+				skipped[[2]string{f.Sections[idx].Name, relocTypeName(f.Machine, r.typ)}]++
+			}
+		}
+	}
+	return data, nil
+}
+
+func symbolValue(symbols []elf.Symbol, bases []uint64, idx uint32) (uint64, uint64, error) {
+	if idx == 0 {
+		return 0, 0, nil
+	}
+	if int(idx) > len(symbols) {
+		return 0, 0, fmt.Errorf("relocation symbol %v out of range", idx)
+	}
+	sym := symbols[idx-1]
+	if int(sym.Section) < len(bases) && sym.Section != elf.SHN_UNDEF {
+		return sym.Value + bases[sym.Section], sym.Value, nil
+	}
+	return sym.Value, sym.Value, nil
+}
+
+func sortSkippedRelocations(skipped map[[2]string]int) []SkippedRelocation {
+	var res []SkippedRelocation
+	for k, n := range skipped {
+		res = append(res, SkippedRelocation{
+			Section: k[0],
+			Type:    k[1],
+			Count:   n,
+		})
+	}
+	sort.Slice(res, func(i, j int) bool {
+		if res[i].Section != res[j].Section {
+			return res[i].Section < res[j].Section
+		}
+		return res[i].Type < res[j].Type
+	})
+	return res
+}
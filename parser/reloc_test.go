@@ -0,0 +1,89 @@
+// =============================================================================
+//  @@-COPYRIGHT-START-@@
+//
+//  Copyright (c) 2024, Qualcomm Innovation Center, Inc. All rights reserved.
+//
+//  Redistribution and use in source and binary forms, with or without
+//  modification, are permitted provided that the following conditions are met:
+//
+//  1. Redistributions of source code must retain the above copyright notice,
+//     this list of conditions and the following disclaimer.
+//
+//  2. Redistributions in binary form must reproduce the above copyright notice,
+//     this list of conditions and the following disclaimer in the documentation
+//     and/or other materials provided with the distribution.
+//
+//  3. Neither the name of the copyright holder nor the names of its contributors
+//     may be used to endorse or promote products derived from this software
+//     without specific prior written permission.
+//
+//  THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS"
+//  AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE
+//  IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE
+//  ARE DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT HOLDER OR CONTRIBUTORS BE
+//  LIABLE FOR ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR
+//  CONSEQUENTIAL DAMAGES (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF
+//  SUBSTITUTE GOODS OR SERVICES; LOSS OF USE, DATA, OR PROFITS; OR BUSINESS
+//  INTERRUPTION) HOWEVER CAUSED AND ON ANY THEORY OF LIABILITY, WHETHER IN
+//  CONTRACT, STRICT LIABILITY, OR TORT (INCLUDING NEGLIGENCE OR OTHERWISE)
+//  ARISING IN ANY WAY OUT OF THE USE OF THIS SOFTWARE, EVEN IF ADVISED OF THE
+//  POSSIBILITY OF SUCH DAMAGE.
+//
+//  SPDX-License-Identifier: BSD-3-Clause
+//
+//  @@-COPYRIGHT-END-@@
+// =============================================================================
+
+package dwarfparser
+
+import (
+	"path/filepath"
+	"testing"
+)
+
+// testdata/reloc holds objects compiled from reloc.ll for several targets
+// with llc -O0 -filetype=obj -mtriple=<target>. All addresses and offsets in
+// their DWARF are relocations against sections at 0.
+func TestRelocatedObjects(t *testing.T) {
+	type lookup struct {
+		pc    uint64
+		fn    string
+		entry uint64
+		line  int
+	}
+	for _, tt := range []struct {
+		file    string
+		lookups []lookup
+	}{
+		{"aarch64.o", []lookup{{0x0, "foo", 0x0, 3}, {0x8, "main", 0x8, 5}, {0x10, "main", 0x8, 6}}},
+		{"armv7.o", []lookup{{0x0, "foo", 0x0, 3}, {0x8, "main", 0x8, 5}, {0x10, "main", 0x8, 6}}},
+		{"hexagon.o", []lookup{{0x0, "foo", 0x0, 3}, {0x10, "main", 0x10, 5}, {0x18, "main", 0x10, 6}}},
+		{"riscv32.o", []lookup{{0x0, "foo", 0x0, 3}, {0x8, "main", 0x8, 5}, {0x14, "main", 0x8, 6}}},
+		{"riscv64.o", []lookup{{0x0, "foo", 0x0, 3}, {0x8, "main", 0x8, 5}, {0x14, "main", 0x8, 6}}},
+		{"x86_64.o", []lookup{{0x0, "foo", 0x0, 2}, {0x10, "main", 0x10, 5}, {0x11, "main", 0x10, 6}}},
+	} {
+		t.Run(tt.file, func(t *testing.T) {
+			b, err := Open(filepath.Join("testdata", "reloc", tt.file))
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer b.Close()
+			skipped, err := b.SkippedRelocations()
+			if err != nil || len(skipped) != 0 {
+				t.Errorf("SkippedRelocations() = %v, %v", skipped, err)
+			}
+			for _, l := range tt.lookups {
+				frames, err := b.Addr2line(l.pc)
+				if err != nil {
+					t.Errorf("Addr2line(0x%x): %v", l.pc, err)
+					continue
+				}
+				f := frames[0]
+				if f.Func != l.fn || f.FuncEntry != l.entry || f.File != "/src/dwarfparser/n.c" || f.Line != l.line {
+					t.Errorf("Addr2line(0x%x) = %v at 0x%x, %v:%v, want %v at 0x%x, /src/dwarfparser/n.c:%v",
+						l.pc, f.Func, f.FuncEntry, f.File, f.Line, l.fn, l.entry, l.line)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,142 @@
+// =============================================================================
+//  @@-COPYRIGHT-START-@@
+//
+//  Copyright (c) 2024, Qualcomm Innovation Center, Inc. All rights reserved.
+//
+//  Redistribution and use in source and binary forms, with or without
+//  modification, are permitted provided that the following conditions are met:
+//
+//  1. Redistributions of source code must retain the above copyright notice,
+//     this list of conditions and the following disclaimer.
+//
+//  2. Redistributions in binary form must reproduce the above copyright notice,
+//     this list of conditions and the following disclaimer in the documentation
+//     and/or other materials provided with the distribution.
+//
+//  3. Neither the name of the copyright holder nor the names of its contributors
+//     may be used to endorse or promote products derived from this software
+//     without specific prior written permission.
+//
+//  THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS"
+//  AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE
+//  IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE
+//  ARE DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT HOLDER OR CONTRIBUTORS BE
+//  LIABLE FOR ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR
+//  CONSEQUENTIAL DAMAGES (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF
+//  SUBSTITUTE GOODS OR SERVICES; LOSS OF USE, DATA, OR PROFITS; OR BUSINESS
+//  INTERRUPTION) HOWEVER CAUSED AND ON ANY THEORY OF LIABILITY, WHETHER IN
+//  CONTRACT, STRICT LIABILITY, OR TORT (INCLUDING NEGLIGENCE OR OTHERWISE)
+//  ARISING IN ANY WAY OUT OF THE USE OF THIS SOFTWARE, EVEN IF ADVISED OF THE
+//  POSSIBILITY OF SUCH DAMAGE.
+//
+//  SPDX-License-Identifier: BSD-3-Clause
+//
+//  @@-COPYRIGHT-END-@@
+// =============================================================================
+
+package dwarfparser
+
+import (
+	"debug/dwarf"
+	"fmt"
+)
+
+// Source languages without namespaces or member functions.
+const (
+	langC89          = 0x1
+	langC            = 0x2
+	langC99          = 0xc
+	langC11          = 0x1d
+	langC17          = 0x2c
+	langMipsAssembly = 0x8001
+)
+
+// scopeOf returns the names of the namespaces and types enclosing the
+// subprogram DIE at off in cu, each followed by "::", like "ns::Foo::".
+func (cu *DWARFCompileUnit) scopeOf(off dwarf.Offset) (string, error) {
+	switch cu.Entry.Val(dwarf.AttrLanguage) {
+	case int64(langC89), int64(langC), int64(langC99), int64(langC11), int64(langC17), int64(langMipsAssembly):
+		return "", nil
+	}
+	scopes, err := cu.scopes()
+	if err != nil {
+		return "", err
+	}
+	return scopes[off], nil
+}
+
+// scopes returns the scope of each subprogram DIE of cu nested in a
+// namespace or type, see scopeOf. It is built once per unit.
+func (cu *DWARFCompileUnit) scopes() (map[dwarf.Offset]string, error) {
+	k := fmt.Sprintf("%v", cu.Entry.Offset)
+	if scopes, ok := cu.Binary.scopesCMap.Get(k); ok {
+		return scopes, nil
+	}
+	scopes := make(map[dwarf.Offset]string)
+	var stack []string
+	r := cu.Dwarf.Reader()
+	r.Seek(cu.Entry.Offset)
+	for {
+		ent, err := r.Next()
+		if err != nil {
+			return nil, err
+		}
+		if ent == nil {
+			break
+		}
+		if ent.Tag == 0 {
+			if len(stack) == 0 {
+				break
+			}
+			stack = stack[:len(stack)-1]
+			if len(stack) == 0 {
+				break
+			}
+			continue
+		}
+		var scope string
+		if len(stack) != 0 {
+			scope = stack[len(stack)-1]
+		}
+		if ent.Tag == dwarf.TagSubprogram && scope != "" {
+			scopes[ent.Offset] = scope
+		}
+		if !ent.Children {
+			if len(stack) == 0 {
+				break
+			}
+			continue
+		}
+		if kind := scopeKind(ent.Tag); kind != "" && len(stack) != 0 {
+			name, err := cu.Binary.stringAttr(ent, dwarf.AttrName)
+			if err != nil {
+				return nil, err
+			}
+			if name == "" {
+				name = "(anonymous " + kind + ")"
+			}
+			scope += name + "::"
+		}
+		stack = append(stack, scope)
+	}
+	cu.Binary.scopesCMap.Set(k, scopes)
+	return scopes, nil
+}
+
+// scopeKind returns the kind of scope opened by a DIE with tag, or "" if
+// the names nested in it are not qualified by it.
+func scopeKind(tag dwarf.Tag) string {
+	switch tag {
+	case dwarf.TagNamespace:
+		return "namespace"
+	case dwarf.TagClassType:
+		return "class"
+	case dwarf.TagStructType:
+		return "struct"
+	case dwarf.TagUnionType:
+		return "union"
+	case dwarf.TagInterfaceType:
+		return "interface"
+	}
+	return ""
+}
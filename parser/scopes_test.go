@@ -0,0 +1,103 @@
+// =============================================================================
+//  @@-COPYRIGHT-START-@@
+//
+//  Copyright (c) 2024, Qualcomm Innovation Center, Inc. All rights reserved.
+//
+//  Redistribution and use in source and binary forms, with or without
+//  modification, are permitted provided that the following conditions are met:
+//
+//  1. Redistributions of source code must retain the above copyright notice,
+//     this list of conditions and the following disclaimer.
+//
+//  2. Redistributions in binary form must reproduce the above copyright notice,
+//     this list of conditions and the following disclaimer in the documentation
+//     and/or other materials provided with the distribution.
+//
+//  3. Neither the name of the copyright holder nor the names of its contributors
+//     may be used to endorse or promote products derived from this software
+//     without specific prior written permission.
+//
+//  THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS"
+//  AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE
+//  IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE
+//  ARE DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT HOLDER OR CONTRIBUTORS BE
+//  LIABLE FOR ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR
+//  CONSEQUENTIAL DAMAGES (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF
+//  SUBSTITUTE GOODS OR SERVICES; LOSS OF USE, DATA, OR PROFITS; OR BUSINESS
+//  INTERRUPTION) HOWEVER CAUSED AND ON ANY THEORY OF LIABILITY, WHETHER IN
+//  CONTRACT, STRICT LIABILITY, OR TORT (INCLUDING NEGLIGENCE OR OTHERWISE)
+//  ARISING IN ANY WAY OUT OF THE USE OF THIS SOFTWARE, EVEN IF ADVISED OF THE
+//  POSSIBILITY OF SUCH DAMAGE.
+//
+//  SPDX-License-Identifier: BSD-3-Clause
+//
+//  @@-COPYRIGHT-END-@@
+// =============================================================================
+
+package dwarfparser
+
+import (
+	"debug/dwarf"
+	"path/filepath"
+	"slices"
+	"testing"
+)
+
+// testdata/cxx.o declares ns::S::get in the struct and defines it out of
+// line with DW_AT_specification. get is inlined into g at 0x10 and has an out
+// of line instance at 0x0, both referring to the definition by
+// DW_AT_abstract_origin.
+func TestQualifiedNames(t *testing.T) {
+	b, err := Open(filepath.Join("testdata", "cxx.o"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer b.Close()
+	type function struct {
+		offset    dwarf.Offset
+		name      string
+		qualified string
+		linkage   string
+		inline    bool
+	}
+	var got []function
+	for fn, err := range b.Funcs() {
+		if err != nil {
+			t.Fatal(err)
+		}
+		got = append(got, function{fn.Offset, fn.Name, fn.QualifiedName, fn.LinkageName, fn.Inline})
+	}
+	want := []function{
+		{0x7e, "g", "g", "_Z1gRN2ns1SEi", false},
+		{0xb6, "get", "ns::S::get", "_ZN2ns1S3getEi", true},
+		{0x11d, "get", "ns::S::get", "_ZN2ns1S3getEi", false},
+	}
+	if !slices.Equal(got, want) {
+		t.Errorf("Funcs() = %v, want %v", got, want)
+	}
+
+	type frame struct {
+		fn        string
+		qualified string
+		linkage   string
+	}
+	for _, tt := range []struct {
+		pc   uint64
+		want []frame
+	}{
+		{0x0, []frame{{"get", "ns::S::get", "_ZN2ns1S3getEi"}}},
+		{0x12, []frame{{"get", "ns::S::get", "_ZN2ns1S3getEi"}, {"g", "g", "_Z1gRN2ns1SEi"}}},
+	} {
+		frames, err := b.Addr2line(tt.pc)
+		if err != nil {
+			t.Fatalf("Addr2line(0x%x): %v", tt.pc, err)
+		}
+		var got []frame
+		for _, f := range frames {
+			got = append(got, frame{f.Func, f.QualifiedFunc, f.LinkageName})
+		}
+		if !slices.Equal(got, tt.want) {
+			t.Errorf("Addr2line(0x%x) = %v, want %v", tt.pc, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,169 @@
+// =============================================================================
+//  @@-COPYRIGHT-START-@@
+//
+//  Copyright (c) 2024, Qualcomm Innovation Center, Inc. All rights reserved.
+//
+//  Redistribution and use in source and binary forms, with or without
+//  modification, are permitted provided that the following conditions are met:
+//
+//  1. Redistributions of source code must retain the above copyright notice,
+//     this list of conditions and the following disclaimer.
+//
+//  2. Redistributions in binary form must reproduce the above copyright notice,
+//     this list of conditions and the following disclaimer in the documentation
+//     and/or other materials provided with the distribution.
+//
+//  3. Neither the name of the copyright holder nor the names of its contributors
+//     may be used to endorse or promote products derived from this software
+//     without specific prior written permission.
+//
+//  THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS"
+//  AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE
+//  IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE
+//  ARE DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT HOLDER OR CONTRIBUTORS BE
+//  LIABLE FOR ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR
+//  CONSEQUENTIAL DAMAGES (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF
+//  SUBSTITUTE GOODS OR SERVICES; LOSS OF USE, DATA, OR PROFITS; OR BUSINESS
+//  INTERRUPTION) HOWEVER CAUSED AND ON ANY THEORY OF LIABILITY, WHETHER IN
+//  CONTRACT, STRICT LIABILITY, OR TORT (INCLUDING NEGLIGENCE OR OTHERWISE)
+//  ARISING IN ANY WAY OUT OF THE USE OF THIS SOFTWARE, EVEN IF ADVISED OF THE
+//  POSSIBILITY OF SUCH DAMAGE.
+//
+//  SPDX-License-Identifier: BSD-3-Clause
+//
+//  @@-COPYRIGHT-END-@@
+// =============================================================================
+
+package dwarfparser
+
+import (
+	"debug/elf"
+	"fmt"
+)
+
+func SectionAddr(path, sec string, off uint64) (uint64, error) {
+	b, release, err := openCached(path)
+	if err != nil {
+		return 0, err
+	}
+	defer release()
+	return b.SectionAddr(sec, off)
+}
+
+// SectionAddr returns the address of offset off in section sec. In
+// relocatable objects, e.g. kernel modules, where every section starts at
+// 0, this is the address used by the DWARF of b, see sectionBases.
+func (b *Binary) SectionAddr(sec string, off uint64) (uint64, error) {
+	f, err := b.File()
+	if err != nil {
+		return 0, err
+	}
+	bases, err := b.sectionBases()
+	if err != nil {
+		return 0, err
+	}
+	for i, s := range f.Sections {
+		if s.Name != sec || s.Flags&elf.SHF_ALLOC == 0 {
+			continue
+		}
+		if off >= s.Size {
+			return 0, fmt.Errorf("offset 0x%x is outside section %v of size 0x%x", off, sec, s.Size)
+		}
+		return bases[i] + off, nil
+	}
+	return 0, fmt.Errorf("%v: no allocated section %v", b.Path, sec)
+}
+
+func AddrSection(path string, addr uint64) (string, uint64, error) {
+	b, release, err := openCached(path)
+	if err != nil {
+		return "", 0, err
+	}
+	defer release()
+	return b.AddrSection(addr)
+}
+
+// AddrSection is the reverse of SectionAddr.
+func (b *Binary) AddrSection(addr uint64) (string, uint64, error) {
+	f, err := b.File()
+	if err != nil {
+		return "", 0, err
+	}
+	bases, err := b.sectionBases()
+	if err != nil {
+		return "", 0, err
+	}
+	for i, s := range f.Sections {
+		if s.Flags&elf.SHF_ALLOC == 0 || addr < bases[i] || addr-bases[i] >= s.Size {
+			continue
+		}
+		return s.Name, addr - bases[i], nil
+	}
+	return "", 0, fmt.Errorf("%v: no section contains 0x%x", b.Path, addr)
+}
+
+func Addr2lineSection(path, sec string, off uint64) ([]Frame, error) {
+	b, release, err := openCached(path)
+	if err != nil {
+		return []Frame{unknownFrame(off)}, err
+	}
+	defer release()
+	return b.Addr2lineSection(sec, off)
+}
+
+// Addr2lineSection is like Addr2line for offset off in section sec.
+func (b *Binary) Addr2lineSection(sec string, off uint64) ([]Frame, error) {
+	pc, err := b.SectionAddr(sec, off)
+	if err != nil {
+		return []Frame{unknownFrame(off)}, err
+	}
+	return b.Addr2line(pc)
+}
+
+func (b *Binary) sectionBases() ([]uint64, error) {
+	f, err := b.File()
+	if err != nil {
+		return nil, err
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.bases == nil {
+		b.bases = sectionBases(f)
+	}
+	return b.bases, nil
+}
+
+// sectionBases returns the address of each section. The allocated sections
+// of a relocatable object all start at 0, so they are laid out one after
+// another like a linker would, .text first so that its offsets stay
+// unchanged.
+func sectionBases(f *elf.File) []uint64 {
+	bases := make([]uint64, len(f.Sections))
+	if f.Type != elf.ET_REL {
+		for i, s := range f.Sections {
+			bases[i] = s.Addr
+		}
+		return bases
+	}
+	var next uint64
+	place := func(i int) {
+		align := max(f.Sections[i].Addralign, 1)
+		next = (next + align - 1) / align * align
+		bases[i] = next
+		next += f.Sections[i].Size
+	}
+	text := -1
+	for i, s := range f.Sections {
+		if s.Name == ".text" && s.Flags&elf.SHF_ALLOC != 0 {
+			place(i)
+			text = i
+			break
+		}
+	}
+	for i, s := range f.Sections {
+		if i != text && s.Flags&elf.SHF_ALLOC != 0 {
+			place(i)
+		}
+	}
+	return bases
+}
@@ -0,0 +1,626 @@
+// =============================================================================
+//  @@-COPYRIGHT-START-@@
+//
+//  Copyright (c) 2024, Qualcomm Innovation Center, Inc. All rights reserved.
+//
+//  Redistribution and use in source and binary forms, with or without
+//  modification, are permitted provided that the following conditions are met:
+//
+//  1. Redistributions of source code must retain the above copyright notice,
+//     this list of conditions and the following disclaimer.
+//
+//  2. Redistributions in binary form must reproduce the above copyright notice,
+//     this list of conditions and the following disclaimer in the documentation
+//     and/or other materials provided with the distribution.
+//
+//  3. Neither the name of the copyright holder nor the names of its contributors
+//     may be used to endorse or promote products derived from this software
+//     without specific prior written permission.
+//
+//  THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS"
+//  AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE
+//  IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE
+//  ARE DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT HOLDER OR CONTRIBUTORS BE
+//  LIABLE FOR ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR
+//  CONSEQUENTIAL DAMAGES (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF
+//  SUBSTITUTE GOODS OR SERVICES; LOSS OF USE, DATA, OR PROFITS; OR BUSINESS
+//  INTERRUPTION) HOWEVER CAUSED AND ON ANY THEORY OF LIABILITY, WHETHER IN
+//  CONTRACT, STRICT LIABILITY, OR TORT (INCLUDING NEGLIGENCE OR OTHERWISE)
+//  ARISING IN ANY WAY OUT OF THE USE OF THIS SOFTWARE, EVEN IF ADVISED OF THE
+//  POSSIBILITY OF SUCH DAMAGE.
+//
+//  SPDX-License-Identifier: BSD-3-Clause
+//
+//  @@-COPYRIGHT-END-@@
+// =============================================================================
+
+package dwarfparser
+
+import (
+	"debug/dwarf"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io/fs"
+	"path/filepath"
+	"slices"
+)
+
+// Split DWARF attributes and forms missing in debug/dwarf.
+const (
+	attrGNUDWOID      dwarf.Attr = 0x2131
+	attrGNURangesBase dwarf.Attr = 0x2132
+	attrGNUAddrBase   dwarf.Attr = 0x2133
+
+	formStrx          = 0x1a
+	formAddrx         = 0x1b
+	formImplicitConst = 0x21
+	formGNUAddrIndex  = 0x1f01
+	formGNUStrIndex   = 0x1f02
+
+	dwUTSkeleton     = 0x04
+	dwUTSplitCompile = 0x05
+)
+
+// Columns of a DWARF package unit index. Id 8 is DW_SECT_MACRO in
+// version 2, which has no range lists.
+const (
+	dwSectInfo       = 1
+	dwSectAbbrev     = 3
+	dwSectLine       = 4
+	dwSectStrOffsets = 6
+	dwSectRnglists   = 8
+)
+
+var errBadDWO = errors.New("malformed split DWARF")
+
+// isSkeleton reports whether cu is the skeleton of a split unit.
+func (cu *DWARFCompileUnit) isSkeleton() bool {
+	return cu.Entry.Tag == dwarf.TagSkeletonUnit || cu.Entry.Val(attrGNUDWOName) != nil
+}
+
+type splitResult struct {
+	cu  *DWARFCompileUnit
+	err error
+}
+
+// splitUnit returns the split unit completing the skeleton unit cu, or nil
+// if cu is not a skeleton. It is taken from the package named after the
+// binary with ".dwp" appended, or else from the .dwo file named by cu,
+// relative to the compilation directory or the directory of the binary.
+func (cu *DWARFCompileUnit) splitUnit() (*DWARFCompileUnit, error) {
+	if !cu.isSkeleton() {
+		return nil, nil
+	}
+	b := cu.Binary
+	k := fmt.Sprintf("%v", cu.Entry.Offset)
+	if e, ok := b.splitsCMap.Get(k); ok {
+		return e.cu, e.err
+	}
+	split, err := cu.loadSplitUnit()
+	if err != nil {
+		err = fmt.Errorf("%v: split unit of 0x%x: %w", b.Path, cu.Entry.Offset, err)
+	}
+	if !b.splitsCMap.SetIfAbsent(k, splitResult{cu: split, err: err}) {
+		if split != nil {
+			split.Binary.Close()
+		}
+		e, _ := b.splitsCMap.Get(k)
+		return e.cu, e.err
+	}
+	return split, err
+}
+
+// dwoState is what the split units of a binary are read with.
+type dwoState struct {
+	order  binary.ByteOrder
+	info   []byte
+	addr   []byte
+	ranges []byte
+	dwp    *Binary
+	index  *dwpIndex
+}
+
+func (b *Binary) dwoState() (*dwoState, error) {
+	b.mu.Lock()
+	if b.dwo != nil {
+		defer b.mu.Unlock()
+		return b.dwo, nil
+	}
+	b.mu.Unlock()
+	st, err := b.loadDWOState()
+	if err != nil {
+		return nil, err
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.dwo != nil {
+		if st.dwp != nil {
+			st.dwp.Close()
+		}
+		return b.dwo, nil
+	}
+	b.dwo = st
+	return st, nil
+}
+
+func (b *Binary) loadDWOState() (*dwoState, error) {
+	db, f, err := b.debugFile()
+	if err != nil {
+		return nil, err
+	}
+	read, err := db.debugSectionReader(f, make(map[[2]string]int))
+	if err != nil {
+		return nil, err
+	}
+	st := &dwoState{order: f.ByteOrder}
+	for i, s := range f.Sections {
+		switch dwarfSuffix(s) {
+		case "info":
+			st.info, err = read(i, s)
+		case "addr":
+			st.addr, err = read(i, s)
+		case "ranges":
+			st.ranges, err = read(i, s)
+		}
+		if err != nil {
+			return nil, err
+		}
+	}
+	st.dwp, err = b.openRelated(b.Path + ".dwp")
+	if errors.Is(err, fs.ErrNotExist) {
+		return st, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if st.index, err = readDWPIndex(st.dwp); err != nil {
+		st.dwp.Close()
+		return nil, fmt.Errorf("%v: %w", st.dwp.Path, err)
+	}
+	return st, nil
+}
+
+// openRelated opens a file belonging to b the way b was opened.
+func (b *Binary) openRelated(path string) (*Binary, error) {
+	if b.mapping != nil {
+		return OpenMmap(path)
+	}
+	return Open(path)
+}
+
+// dwoSections are the debug sections of one split unit.
+type dwoSections struct {
+	info       []byte
+	abbrev     []byte
+	line       []byte
+	str        []byte
+	strOffsets []byte
+	rnglists   []byte
+}
+
+func (cu *DWARFCompileUnit) loadSplitUnit() (*DWARFCompileUnit, error) {
+	b := cu.Binary
+	st, err := b.dwoState()
+	if err != nil {
+		return nil, err
+	}
+	id, hasID := dwoID(cu.Entry, st.info, st.order)
+	var sb *Binary
+	var secs *dwoSections
+	if st.index != nil && hasID {
+		if secs, err = st.index.sections(st.dwp, id); err != nil {
+			return nil, err
+		}
+		if secs != nil {
+			sb = newBinary(st.dwp.Path, nil)
+		}
+	}
+	if sb == nil {
+		if sb, err = cu.openDWO(); err != nil {
+			return nil, err
+		}
+		if secs, err = readDWOSections(sb); err != nil {
+			sb.Close()
+			return nil, err
+		}
+	}
+	split, err := cu.newSplitUnit(sb, secs, st)
+	if err != nil {
+		sb.Close()
+		return nil, fmt.Errorf("%v: %w", sb.Path, err)
+	}
+	if splitID, ok := dwoID(split.Entry, secs.info, st.order); hasID && ok && splitID != id {
+		sb.Close()
+		return nil, fmt.Errorf("%v: DWO id 0x%x, want 0x%x", sb.Path, splitID, id)
+	}
+	return split, nil
+}
+
+// openDWO opens the .dwo file named by the skeleton unit cu.
+func (cu *DWARFCompileUnit) openDWO() (*Binary, error) {
+	name, err := cu.Binary.stringAttr(cu.Entry, attrDWOName)
+	if err == nil && name == "" {
+		name, err = cu.Binary.stringAttr(cu.Entry, attrGNUDWOName)
+	}
+	if err != nil {
+		return nil, err
+	}
+	dir := filepath.Dir(cu.Binary.Path)
+	var paths []string
+	if filepath.IsAbs(name) {
+		paths = append(paths, name)
+	} else {
+		if cu.CompDir != "" {
+			paths = append(paths, filepath.Join(cu.CompDir, name))
+		}
+		paths = append(paths, filepath.Join(dir, name))
+	}
+	paths = append(paths, filepath.Join(dir, filepath.Base(name)))
+	for _, path := range paths {
+		dwo, err := cu.Binary.openRelated(path)
+		if errors.Is(err, fs.ErrNotExist) {
+			continue
+		}
+		return dwo, err
+	}
+	return nil, fmt.Errorf("%w: %v", ErrNoSplitDWARF, name)
+}
+
+func readDWOSections(dwo *Binary) (*dwoSections, error) {
+	f, err := dwo.File()
+	if err != nil {
+		return nil, err
+	}
+	secs := &dwoSections{}
+	for _, s := range f.Sections {
+		var p *[]byte
+		switch dwarfSuffix(s) {
+		case "info.dwo":
+			p = &secs.info
+		case "abbrev.dwo":
+			p = &secs.abbrev
+		case "line.dwo":
+			p = &secs.line
+		case "str.dwo":
+			p = &secs.str
+		case "str_offsets.dwo":
+			p = &secs.strOffsets
+		case "rnglists.dwo":
+			p = &secs.rnglists
+		default:
+			continue
+		}
+		if *p, err = dwo.sectionData(s); err != nil {
+			return nil, err
+		}
+	}
+	if secs.info == nil {
+		return nil, fmt.Errorf("%v: %w", dwo.Path, ErrNoDebugInfo)
+	}
+	return secs, nil
+}
+
+// newSplitUnit sets up the DWARF of sb from the split unit sections secs
+// and returns the split unit of the skeleton cu.
+//
+// debug/dwarf does not know the bases a split unit inherits from its
+// skeleton, so the sections are cut to start at them: .debug_addr and, up
+// to DWARF 4, .debug_ranges of the binary at the bases given by the
+// skeleton, and .debug_str_offsets.dwo and .debug_rnglists.dwo after their
+// header.
+func (cu *DWARFCompileUnit) newSplitUnit(sb *Binary, secs *dwoSections, st *dwoState) (*DWARFCompileUnit, error) {
+	version, dwarf64, ok := unitVersion(secs.info, st.order)
+	if !ok {
+		return nil, errBadDWO
+	}
+	abbrev := secs.abbrev
+	var ranges []byte
+	if version < 5 {
+		var err error
+		if abbrev, err = rewriteGNUSplitForms(abbrev); err != nil {
+			return nil, err
+		}
+		ranges = cutAt(st.ranges, cu.Entry.Val(attrGNURangesBase))
+	}
+	d, err := dwarf.New(abbrev, nil, nil, secs.info, secs.line, nil, ranges, secs.str)
+	if err != nil {
+		return nil, err
+	}
+	strOffsets, rnglists := secs.strOffsets, secs.rnglists
+	if version >= 5 {
+		// Skip unit_length, version and padding, and in .debug_rnglists.dwo
+		// the address and segment selector sizes and offset_entry_count.
+		strHdr, rngHdr := int64(8), int64(12)
+		if dwarf64 {
+			strHdr, rngHdr = 16, 20
+		}
+		strOffsets = cutAt(strOffsets, strHdr)
+		rnglists = cutAt(rnglists, rngHdr)
+	}
+	addrBase := cu.Entry.Val(dwarf.AttrAddrBase)
+	if addrBase == nil {
+		addrBase = cu.Entry.Val(attrGNUAddrBase)
+	}
+	for name, data := range map[string][]byte{
+		".debug_str_offsets": strOffsets,
+		".debug_rnglists":    rnglists,
+		".debug_addr":        cutAt(st.addr, addrBase),
+	} {
+		if data == nil {
+			continue
+		}
+		if err := d.AddSection(name, data); err != nil {
+			return nil, err
+		}
+	}
+	sb.mu.Lock()
+	sb.dwarf = d
+	sb.mu.Unlock()
+	units, err := sb.allUnits()
+	if err != nil {
+		return nil, err
+	}
+	i := slices.IndexFunc(units, func(u *DWARFCompileUnit) bool {
+		return u.Entry.Tag == dwarf.TagCompileUnit
+	})
+	if i < 0 {
+		return nil, fmt.Errorf("%w: no split compile unit", errBadDWO)
+	}
+	split := units[i]
+	// The line table header of .debug_line.dwo is that of the split unit,
+	// which it doesn't point to.
+	ent := *split.Entry
+	ent.Field = slices.Clone(ent.Field)
+	if secs.line != nil && ent.Val(dwarf.AttrStmtList) == nil {
+		ent.Field = append(ent.Field, dwarf.Field{Attr: dwarf.AttrStmtList, Val: int64(0), Class: dwarf.ClassLinePtr})
+	}
+	if split.CompDir == "" {
+		split.CompDir = cu.CompDir
+		ent.Field = append(ent.Field, dwarf.Field{Attr: dwarf.AttrCompDir, Val: cu.CompDir, Class: dwarf.ClassString})
+	}
+	split.Entry = &ent
+	if split.Name == "" {
+		split.Name = cu.Name
+	}
+	split.Ranges = cu.Ranges
+	return split, nil
+}
+
+// cutAt returns data from the offset base on, or nil if base is not an
+// offset into data.
+func cutAt(data []byte, base any) []byte {
+	off, ok := base.(int64)
+	if !ok || off < 0 || off > int64(len(data)) {
+		return nil
+	}
+	return data[off:]
+}
+
+// unitVersion returns the version of the first unit in info and whether it
+// is in the 64-bit DWARF format.
+func unitVersion(info []byte, order binary.ByteOrder) (int, bool, bool) {
+	if len(info) < 6 {
+		return 0, false, false
+	}
+	if order.Uint32(info) != 0xffffffff {
+		return int(order.Uint16(info[4:])), false, true
+	}
+	if len(info) < 14 {
+		return 0, false, false
+	}
+	return int(order.Uint16(info[12:])), true, true
+}
+
+// dwoID returns the DWO id of the skeleton or split unit DIE ent, held by
+// DW_AT_GNU_dwo_id before DWARF 5 and at the end of the unit header, right
+// before ent, since. info is the section containing ent.
+func dwoID(ent *dwarf.Entry, info []byte, order binary.ByteOrder) (uint64, bool) {
+	if id, ok := ent.Val(attrGNUDWOID).(int64); ok {
+		return uint64(id), true
+	}
+	off := int(ent.Offset)
+	if off > len(info) {
+		return 0, false
+	}
+	// The header of a DWARF 5 skeleton or split compile unit is 20 bytes
+	// long, 32 in the 64-bit format, with the version after the length.
+	for _, dwarf64 := range []bool{false, true} {
+		size, lenSize := 20, 4
+		if dwarf64 {
+			size, lenSize = 32, 12
+		}
+		start := off - size
+		if start < 0 || dwarf64 != (order.Uint32(info[start:]) == 0xffffffff) {
+			continue
+		}
+		hdr := info[start+lenSize : off]
+		if order.Uint16(hdr) == 5 && (hdr[2] == dwUTSkeleton || hdr[2] == dwUTSplitCompile) {
+			return order.Uint64(info[off-8:]), true
+		}
+	}
+	return 0, false
+}
+
+// rewriteGNUSplitForms returns the abbreviation tables abbrev with the
+// DW_FORM_GNU_addr_index and DW_FORM_GNU_str_index forms of DWARF 4 split
+// units replaced by DW_FORM_addrx and DW_FORM_strx, which debug/dwarf knows
+// and which are encoded the same. Tables after the first one move.
+func rewriteGNUSplitForms(abbrev []byte) ([]byte, error) {
+	var out []byte
+	last, off := 0, 0
+	uleb := func() uint64 {
+		var v uint64
+		for shift := uint(0); off < len(abbrev); shift += 7 {
+			c := abbrev[off]
+			off++
+			if shift < 64 {
+				v |= uint64(c&0x7f) << shift
+			}
+			if c&0x80 == 0 {
+				return v
+			}
+		}
+		off = len(abbrev) + 1
+		return 0
+	}
+	for off < len(abbrev) {
+		if uleb() == 0 {
+			continue
+		}
+		uleb()
+		off++
+		for off < len(abbrev) {
+			attr := uleb()
+			start := off
+			form := uleb()
+			if attr == 0 && form == 0 {
+				break
+			}
+			if form == formImplicitConst {
+				uleb()
+			}
+			var repl byte
+			switch form {
+			case formGNUAddrIndex:
+				repl = formAddrx
+			case formGNUStrIndex:
+				repl = formStrx
+			default:
+				continue
+			}
+			out = append(out, abbrev[last:start]...)
+			out = append(out, repl)
+			last = off
+		}
+	}
+	if off > len(abbrev) {
+		return nil, fmt.Errorf("%w: truncated .debug_abbrev.dwo", errBadDWO)
+	}
+	if out == nil {
+		return abbrev, nil
+	}
+	return append(out, abbrev[last:]...), nil
+}
+
+// dwpIndex is the .debug_cu_index of a DWARF package, version 2 as written
+// by GNU dwp for DWARF 4 or version 5.
+type dwpIndex struct {
+	version int
+	rows    map[uint64]int
+	cols    []uint32
+	offsets []uint32
+	sizes   []uint32
+}
+
+func readDWPIndex(dwp *Binary) (*dwpIndex, error) {
+	f, err := dwp.File()
+	if err != nil {
+		return nil, err
+	}
+	s := debugSection(f, ".debug_cu_index")
+	if s == nil {
+		return nil, fmt.Errorf("%w: no .debug_cu_index", errBadDWO)
+	}
+	data, err := dwp.sectionData(s)
+	if err != nil {
+		return nil, err
+	}
+	order := f.ByteOrder
+	bad := fmt.Errorf("%w: malformed .debug_cu_index", errBadDWO)
+	if len(data) < 16 {
+		return nil, bad
+	}
+	version := 5
+	if v := order.Uint32(data); v == 2 {
+		version = 2
+	} else if order.Uint16(data) != 5 {
+		return nil, fmt.Errorf("%w: .debug_cu_index version %v", errBadDWO, v)
+	}
+	ncols := uint64(order.Uint32(data[4:]))
+	nunits := uint64(order.Uint32(data[8:]))
+	nslots := uint64(order.Uint32(data[12:]))
+	if 16+nslots*12+ncols*4+2*nunits*ncols*4 > uint64(len(data)) {
+		return nil, bad
+	}
+	words := func(off, n uint64) []uint32 {
+		w := make([]uint32, n)
+		for i := range w {
+			w[i] = order.Uint32(data[off+uint64(i)*4:])
+		}
+		return w
+	}
+	idx := &dwpIndex{version: version, rows: make(map[uint64]int)}
+	off := uint64(16)
+	rows := words(off+nslots*8, nslots)
+	for i, row := range rows {
+		if row != 0 && uint64(row) <= nunits {
+			idx.rows[order.Uint64(data[off+uint64(i)*8:])] = int(row) - 1
+		}
+	}
+	off += nslots * 12
+	idx.cols = words(off, ncols)
+	off += ncols * 4
+	idx.offsets = words(off, nunits*ncols)
+	idx.sizes = words(off+nunits*ncols*4, nunits*ncols)
+	return idx, nil
+}
+
+// sections returns the contributions of the unit with DWO id to the sections
+// of dwp, or nil if the package has no such unit.
+func (idx *dwpIndex) sections(dwp *Binary, id uint64) (*dwoSections, error) {
+	row, ok := idx.rows[id]
+	if !ok {
+		return nil, nil
+	}
+	f, err := dwp.File()
+	if err != nil {
+		return nil, err
+	}
+	read := func(name string) ([]byte, error) {
+		s := debugSection(f, name)
+		if s == nil {
+			return nil, nil
+		}
+		return dwp.sectionData(s)
+	}
+	secs := &dwoSections{}
+	if secs.str, err = read(".debug_str.dwo"); err != nil {
+		return nil, err
+	}
+	for col, sect := range idx.cols {
+		var p *[]byte
+		var name string
+		switch sect {
+		case dwSectInfo:
+			p, name = &secs.info, ".debug_info.dwo"
+		case dwSectAbbrev:
+			p, name = &secs.abbrev, ".debug_abbrev.dwo"
+		case dwSectLine:
+			p, name = &secs.line, ".debug_line.dwo"
+		case dwSectStrOffsets:
+			p, name = &secs.strOffsets, ".debug_str_offsets.dwo"
+		case dwSectRnglists:
+			if idx.version < 5 {
+				continue
+			}
+			p, name = &secs.rnglists, ".debug_rnglists.dwo"
+		default:
+			continue
+		}
+		data, err := read(name)
+		if err != nil {
+			return nil, err
+		}
+		i := row*len(idx.cols) + col
+		off, size := uint64(idx.offsets[i]), uint64(idx.sizes[i])
+		if off+size > uint64(len(data)) {
+			return nil, fmt.Errorf("%w: %v contribution out of range", errBadDWO, name)
+		}
+		*p = data[off : off+size]
+	}
+	if secs.info == nil {
+		return nil, fmt.Errorf("%w: no .debug_info.dwo contribution", errBadDWO)
+	}
+	return secs, nil
+}
@@ -0,0 +1,167 @@
+// =============================================================================
+//  @@-COPYRIGHT-START-@@
+//
+//  Copyright (c) 2024, Qualcomm Innovation Center, Inc. All rights reserved.
+//
+//  Redistribution and use in source and binary forms, with or without
+//  modification, are permitted provided that the following conditions are met:
+//
+//  1. Redistributions of source code must retain the above copyright notice,
+//     this list of conditions and the following disclaimer.
+//
+//  2. Redistributions in binary form must reproduce the above copyright notice,
+//     this list of conditions and the following disclaimer in the documentation
+//     and/or other materials provided with the distribution.
+//
+//  3. Neither the name of the copyright holder nor the names of its contributors
+//     may be used to endorse or promote products derived from this software
+//     without specific prior written permission.
+//
+//  THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS"
+//  AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE
+//  IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE
+//  ARE DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT HOLDER OR CONTRIBUTORS BE
+//  LIABLE FOR ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR
+//  CONSEQUENTIAL DAMAGES (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF
+//  SUBSTITUTE GOODS OR SERVICES; LOSS OF USE, DATA, OR PROFITS; OR BUSINESS
+//  INTERRUPTION) HOWEVER CAUSED AND ON ANY THEORY OF LIABILITY, WHETHER IN
+//  CONTRACT, STRICT LIABILITY, OR TORT (INCLUDING NEGLIGENCE OR OTHERWISE)
+//  ARISING IN ANY WAY OUT OF THE USE OF THIS SOFTWARE, EVEN IF ADVISED OF THE
+//  POSSIBILITY OF SUCH DAMAGE.
+//
+//  SPDX-License-Identifier: BSD-3-Clause
+//
+//  @@-COPYRIGHT-END-@@
+// =============================================================================
+
+package dwarfparser
+
+import (
+	"debug/dwarf"
+	"encoding/binary"
+	"errors"
+	"path/filepath"
+	"slices"
+	"testing"
+)
+
+// The split DWARF of testdata/split is in split1.dwo and split2.dwo (DWARF 5),
+// that of split4dwo in split4dwo-1.dwo and split4dwo-2.dwo (DWARF 4), and
+// that of split4dwp and split5dwp in packages next to them. All are linked
+// from split1.c and split2.c, whose skeleton units have no functions.
+func TestSplitUnits(t *testing.T) {
+	type lookup struct {
+		pc   uint64
+		fn   string
+		file string
+		line int
+	}
+	lookups := []lookup{
+		{0x401000, "helper", "split1.c", 1},
+		{0x401005, "f1", "split1.c", 2},
+		{0x40100a, "helper", "split2.c", 1},
+		{0x40100f, "f2", "split2.c", 2},
+	}
+	for _, tt := range []struct {
+		file  string
+		files []string
+	}{
+		{"split", []string{"split1.dwo", "split2.dwo"}},
+		{"split4dwo", []string{"split4dwo-1.dwo", "split4dwo-2.dwo"}},
+		{"split4dwp", []string{"split4dwp.dwp"}},
+		{"split5dwp", []string{"split5dwp.dwp"}},
+	} {
+		t.Run(tt.file, func(t *testing.T) {
+			b, err := Open(filepath.Join("testdata", tt.file))
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer b.Close()
+			for _, l := range lookups {
+				frames, err := b.Addr2line(l.pc)
+				if err != nil {
+					t.Errorf("Addr2line(0x%x): %v", l.pc, err)
+					continue
+				}
+				f := frames[0]
+				if f.Func != l.fn || f.File != l.file || f.Line != l.line {
+					t.Errorf("Addr2line(0x%x) = %v, %v:%v, want %v, %v:%v", l.pc, f.Func, f.File, f.Line, l.fn, l.file, l.line)
+				}
+			}
+
+			// A copy without the split DWARF next to it can't find it.
+			dir := t.TempDir()
+			copyFile(t, filepath.Join(dir, tt.file), filepath.Join("testdata", tt.file))
+			skel, err := Open(filepath.Join(dir, tt.file))
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer skel.Close()
+			if _, err := skel.Addr2line(lookups[0].pc); !errors.Is(err, ErrNoSplitDWARF) {
+				t.Errorf("Addr2line(0x%x) without %v = %v, want %v", lookups[0].pc, tt.files, err, ErrNoSplitDWARF)
+			}
+		})
+	}
+}
+
+// testdata/split is linked from split1.c and split2.c, built with
+// -gsplit-dwarf into split1.dwo and split2.dwo. Both units have the same
+// layout, so their DIEs are at the same offsets in their .dwo files.
+func TestLookupNameSplitUnits(t *testing.T) {
+	b, err := Open(filepath.Join("testdata", "split"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer b.Close()
+	for _, tt := range []struct {
+		name string
+		want []string
+	}{
+		{"helper", []string{"split1.c", "split2.c"}},
+		{"int", []string{"split1.c", "split2.c"}},
+		{"f2", []string{"split2.c"}},
+	} {
+		ents, err := b.LookupName(tt.name)
+		if err != nil {
+			t.Fatalf("LookupName(%q): %v", tt.name, err)
+		}
+		var got []string
+		for _, ent := range ents {
+			got = append(got, filepath.Base(ent.CU.Name))
+		}
+		if !slices.Equal(got, tt.want) {
+			t.Errorf("LookupName(%q) in units %q, want %q", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestDWOID(t *testing.T) {
+	le := binary.LittleEndian
+	// unit_length, version, unit_type and address_size, debug_abbrev_offset
+	// and the DWO id of a DWARF 5 skeleton unit.
+	v5 := le.AppendUint32(nil, 0x30)
+	v5 = append(v5, 5, 0, dwUTSkeleton, 8)
+	v5 = le.AppendUint32(v5, 0)
+	v5 = le.AppendUint64(v5, 0x1122334455667788)
+	v5 = append(v5, 1)
+	// unit_length, version, debug_abbrev_offset and address_size of a
+	// DWARF 4 unit.
+	v4 := le.AppendUint32(nil, 0x30)
+	v4 = append(v4, 4, 0, 0, 0, 0, 0, 8, 1)
+	for _, tt := range []struct {
+		name  string
+		info  []byte
+		off   int
+		id    uint64
+		hasID bool
+	}{
+		{"DWARF 5", v5, 20, 0x1122334455667788, true},
+		{"DWARF 4", v4, 11, 0, false},
+		{"DWARF 4 after DWARF 5", append(slices.Clone(v5), v4...), len(v5) + 11, 0, false},
+	} {
+		id, ok := dwoID(&dwarf.Entry{Offset: dwarf.Offset(tt.off)}, tt.info, le)
+		if id != tt.id || ok != tt.hasID {
+			t.Errorf("%v: dwoID() = 0x%x, %v, want 0x%x, %v", tt.name, id, ok, tt.id, tt.hasID)
+		}
+	}
+}
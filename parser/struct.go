@@ -38,15 +38,40 @@ package dwarfparser
 
 import "debug/dwarf"
 
+// Frame is one source location of a PC, innermost first. Func and the
+// fields after Column describe the function the location is in, the
+// subprogram or the inlined subroutine. Inline is set for the call sites of
+// inlined functions.
 type Frame struct {
-	PC     uint64
-	Func   string
-	File   string
-	Line   int
-	Inline bool
+	PC   uint64
+	Func string
+	// QualifiedFunc is Func prefixed with the enclosing namespaces and
+	// types, like ns::Foo::bar. LinkageName is the mangled name, if any.
+	QualifiedFunc string
+	LinkageName   string
+	File          string
+	Line          int
+	Column        int
+	Discriminator int
+	Inline        bool
+	// FuncEntry is the entry address of the function. FuncOffset and
+	// FuncSize are the offset of PC from the lowest address of the
+	// function and the span of its address ranges from there, like
+	// func+0x24/0x80 in kernel backtraces. For a function split into hot
+	// and cold parts the span includes whatever lies between them.
+	FuncEntry  uint64
+	FuncOffset uint64
+	FuncSize   uint64
+	// StartFile and StartLine are the declaration of the function.
+	StartFile string
+	StartLine int
+	CUName    string
+	CompDir   string
+	DIEOffset dwarf.Offset
 }
 
 type DWARFCompileUnit struct {
+	Binary   *Binary
 	FilePath string
 	Dwarf    *dwarf.Data
 	Entry    *dwarf.Entry
@@ -60,7 +85,10 @@ type DWARFFunction struct {
 	OriginAbstract   *DWARFFunction
 	Type             dwarf.Tag
 	Name             string
+	QualifiedName    string
+	LinkageName      string
 	Ranges           [][2]uint64
+	EntryPC          uint64
 	DeclFile         string
 	DeclLine         int
 	CallFile         string
@@ -37,10 +37,21 @@
 package dwarfparser
 
 import (
+	"bytes"
+	"debug/dwarf"
 	"debug/elf"
+	"fmt"
+	"iter"
+	"sort"
 	"strings"
 )
 
+// SymbolTable is a Provider using only the ELF symbol table, which gives
+// function names but no file or line.
+type SymbolTable struct {
+	funcs []elf.Symbol
+}
+
 type TracePCInfo struct {
 	textAddr    uint64
 	tracePC     uint64
@@ -50,25 +61,130 @@ type TracePCInfo struct {
 }
 
 func FindAllSymbols(path string) ([]elf.Symbol, error) {
-	f, err := elf.Open(path)
+	b, release, err := openCached(path)
+	if err != nil {
+		return nil, err
+	}
+	defer release()
+	return b.FindAllSymbols()
+}
+
+func (b *Binary) FindAllSymbols() ([]elf.Symbol, error) {
+	f, err := b.File()
 	if err != nil {
 		return nil, err
 	}
-	defer f.Close()
-	symbols, err := f.Symbols()
+	b.mu.Lock()
+	symbols := b.symbols
+	b.mu.Unlock()
+	if symbols != nil {
+		return symbols, nil
+	}
+	if b.mapping != nil {
+		symbols, err = b.readSymbols(f)
+	} else {
+		symbols, err = f.Symbols()
+	}
+	if err == elf.ErrNoSymbols {
+		// A stripped binary takes those of its debug file.
+		if db, err1 := b.debugBinary(); err1 == nil && db != b {
+			symbols, err = db.FindAllSymbols()
+		}
+	}
+	if err != nil {
+		return nil, err
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.symbols == nil {
+		b.symbols = symbols
+	}
+	return b.symbols, nil
+}
+
+// readSymbols is like elf.File.Symbols but reads .symtab and its string
+// table through b.sectionData.
+func (b *Binary) readSymbols(f *elf.File) ([]elf.Symbol, error) {
+	symtab := f.SectionByType(elf.SHT_SYMTAB)
+	if symtab == nil {
+		return nil, elf.ErrNoSymbols
+	}
+	if int(symtab.Link) <= 0 || int(symtab.Link) >= len(f.Sections) {
+		return nil, fmt.Errorf("section %v has invalid string table link %v", symtab.Name, symtab.Link)
+	}
+	data, err := b.sectionData(symtab)
 	if err != nil {
 		return nil, err
 	}
+	strs, err := b.sectionData(f.Sections[symtab.Link])
+	if err != nil {
+		return nil, err
+	}
+	size := elf.Sym32Size
+	if f.Class == elf.ELFCLASS64 {
+		size = elf.Sym64Size
+	}
+	if len(data)%size != 0 {
+		return nil, fmt.Errorf("length of symbol section is not a multiple of %v", size)
+	}
+	if len(data) == 0 {
+		return nil, elf.ErrNoSymbols
+	}
+	bo := f.ByteOrder
+	// The first entry is the null symbol.
+	data = data[size:]
+	symbols := make([]elf.Symbol, 0, len(data)/size)
+	for ; len(data) >= size; data = data[size:] {
+		var sym elf.Symbol
+		var name uint32
+		if f.Class == elf.ELFCLASS64 {
+			name = bo.Uint32(data[0:])
+			sym.Info = data[4]
+			sym.Other = data[5]
+			sym.Section = elf.SectionIndex(bo.Uint16(data[6:]))
+			sym.Value = bo.Uint64(data[8:])
+			sym.Size = bo.Uint64(data[16:])
+		} else {
+			name = bo.Uint32(data[0:])
+			sym.Value = uint64(bo.Uint32(data[4:]))
+			sym.Size = uint64(bo.Uint32(data[8:]))
+			sym.Info = data[12]
+			sym.Other = data[13]
+			sym.Section = elf.SectionIndex(bo.Uint16(data[14:]))
+		}
+		sym.Name = cString(strs, name)
+		symbols = append(symbols, sym)
+	}
 	return symbols, nil
 }
 
+func cString(strs []byte, off uint32) string {
+	if int(off) >= len(strs) {
+		return ""
+	}
+	end := bytes.IndexByte(strs[off:], 0)
+	if end < 0 {
+		return string(strs[off:])
+	}
+	return string(strs[off : int(off)+end])
+}
+
 func FindAllSymbolsInSec(path, sec string) ([]elf.Symbol, error) {
+	b, release, err := openCached(path)
+	if err != nil {
+		return nil, err
+	}
+	defer release()
+	return b.FindAllSymbolsInSec(sec)
+}
+
+func (b *Binary) FindAllSymbolsInSec(sec string) ([]elf.Symbol, error) {
 	var finalSymbols []elf.Symbol
-	symbols, err := FindAllSymbols(path)
+	symbols, err := b.FindAllSymbols()
 	if err != nil {
 		return nil, err
 	}
-	idx, err := GetSectionIdx(path, sec)
+	idx, err := b.GetSectionIdx(sec)
 	if err != nil {
 		return nil, err
 	}
@@ -81,21 +197,30 @@ func FindAllSymbolsInSec(path, sec string) ([]elf.Symbol, error) {
 }
 
 func GetTracePCInfo(path string) (*TracePCInfo, error) {
-	symbols, err := FindAllSymbols(path)
+	b, release, err := openCached(path)
+	if err != nil {
+		return nil, err
+	}
+	defer release()
+	return b.GetTracePCInfo()
+}
+
+func (b *Binary) GetTracePCInfo() (*TracePCInfo, error) {
+	symbols, err := b.FindAllSymbols()
 	if err != nil {
 		return nil, err
 	}
-	f, err := elf.Open(path)
+	f, err := b.File()
 	if err != nil {
 		return nil, err
 	}
-	defer f.Close()
 	text, err := GetSectionByName(f, ".text")
 	if err != nil {
 		return nil, err
 	}
 	info := &TracePCInfo{
 		textAddr:    text.Addr,
+		traceCmp:    make(map[uint64]bool),
 		tracePCIdx:  make(map[int]bool),
 		traceCmpIdx: make(map[int]bool),
 	}
@@ -117,3 +242,96 @@ func GetTracePCInfo(path string) (*TracePCInfo, error) {
 	}
 	return info, nil
 }
+
+func NewSymbolTable(b *Binary) (*SymbolTable, error) {
+	symbols, err := b.FindAllSymbols()
+	if err != nil {
+		return nil, err
+	}
+	bases, err := b.sectionBases()
+	if err != nil {
+		return nil, err
+	}
+	f, err := b.File()
+	if err != nil {
+		return nil, err
+	}
+	var funcs []elf.Symbol
+	for _, s := range symbols {
+		if elf.ST_TYPE(s.Info) != elf.STT_FUNC || (s.Value == 0 && f.Type != elf.ET_REL) {
+			continue
+		}
+		// Functions of relocatable objects are placed like their DWARF.
+		if f.Type == elf.ET_REL && int(s.Section) < len(bases) {
+			s.Value += bases[s.Section]
+		}
+		funcs = append(funcs, s)
+	}
+	sort.SliceStable(funcs, func(i, j int) bool {
+		return funcs[i].Value < funcs[j].Value
+	})
+	return &SymbolTable{
+		funcs: funcs,
+	}, nil
+}
+
+func (st *SymbolTable) Addr2line(pc uint64) ([]Frame, error) {
+	idx := sort.Search(len(st.funcs), func(i int) bool {
+		return st.funcs[i].Value > pc
+	})
+	// Several symbols may alias the same address, use the first one whose
+	// size covers pc.
+	for i := idx - 1; i >= 0 && st.funcs[i].Value == st.funcs[idx-1].Value; i-- {
+		s := st.funcs[i]
+		if pc < s.Value+s.Size || (s.Size == 0 && pc == s.Value) {
+			return []Frame{
+				{
+					PC:          pc,
+					Func:        s.Name,
+					LinkageName: s.Name,
+					File:        "??",
+					FuncEntry:   s.Value,
+					FuncOffset:  pc - s.Value,
+					FuncSize:    s.Size,
+				},
+			}, nil
+		}
+	}
+	return []Frame{unknownFrame(pc)}, &LookupError{PC: pc, Err: ErrNoSubprogram}
+}
+
+func (st *SymbolTable) FuncsByName(name string) ([]*DWARFFunction, error) {
+	var funcs []*DWARFFunction
+	for _, s := range st.funcs {
+		if s.Name == name {
+			funcs = append(funcs, symbolFunc(s))
+		}
+	}
+	return funcs, nil
+}
+
+func (st *SymbolTable) Funcs() iter.Seq2[*DWARFFunction, error] {
+	return func(yield func(*DWARFFunction, error) bool) {
+		for _, s := range st.funcs {
+			if !yield(symbolFunc(s), nil) {
+				return
+			}
+		}
+	}
+}
+
+// Close is a no-op, the symbols belong to the Binary.
+func (st *SymbolTable) Close() error {
+	return nil
+}
+
+func symbolFunc(s elf.Symbol) *DWARFFunction {
+	return &DWARFFunction{
+		Type:        dwarf.TagSubprogram,
+		Name:        s.Name,
+		LinkageName: s.Name,
+		Ranges:      [][2]uint64{{s.Value, s.Value + s.Size}},
+		EntryPC:     s.Value,
+		Depth:       1,
+	}
+}
@@ -0,0 +1,10 @@
+__attribute__((noinline, cold)) void fail(int x) { __builtin_trap(); }
+
+int work(int x)
+{
+	if (__builtin_expect(x < 0, 0)) {
+		fail(x);
+		return -x * 3;
+	}
+	return x * 2;
+}
@@ -0,0 +1,15 @@
+struct point {
+	int x, y;
+};
+
+int add(int a, int b);
+
+static inline __attribute__((always_inline)) int twice(int x)
+{
+	return add(x, x);
+}
+
+int start(struct point *p)
+{
+	return twice(p->x) + p->y;
+}
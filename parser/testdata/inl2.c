@@ -0,0 +1,7 @@
+int counter;
+
+int add(int a, int b)
+{
+	counter++;
+	return a + b;
+}
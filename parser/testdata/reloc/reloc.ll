@@ -0,0 +1,30 @@
+
+@gvar = dso_local global i32 7, align 4, !dbg !20
+
+define dso_local i32 @foo(i32 %x) !dbg !10 {
+  %r = add i32 %x, 1, !dbg !15
+  ret i32 %r, !dbg !15
+}
+
+define dso_local i32 @main() !dbg !16 {
+  %r = call i32 @foo(i32 1), !dbg !17
+  ret i32 %r, !dbg !17
+}
+
+!llvm.dbg.cu = !{!0}
+!llvm.module.flags = !{!3, !4}
+
+!0 = distinct !DICompileUnit(language: DW_LANG_C99, file: !1, producer: "hand", isOptimized: false, runtimeVersion: 0, emissionKind: FullDebug, globals: !22, nameTableKind: Default)
+!1 = !DIFile(filename: "n.c", directory: "/src/dwarfparser")
+!3 = !{i32 7, !"Dwarf Version", i32 5}
+!4 = !{i32 2, !"Debug Info Version", i32 3}
+!10 = distinct !DISubprogram(name: "foo", scope: !1, file: !1, line: 2, type: !11, scopeLine: 2, spFlags: DISPFlagDefinition, unit: !0)
+!11 = !DISubroutineType(types: !12)
+!12 = !{!13, !13}
+!13 = !DIBasicType(name: "int", size: 32, encoding: DW_ATE_signed)
+!15 = !DILocation(line: 3, column: 3, scope: !10)
+!16 = distinct !DISubprogram(name: "main", scope: !1, file: !1, line: 5, type: !11, scopeLine: 5, spFlags: DISPFlagDefinition, unit: !0)
+!17 = !DILocation(line: 6, column: 3, scope: !16)
+!20 = !DIGlobalVariableExpression(var: !21, expr: !DIExpression())
+!21 = distinct !DIGlobalVariable(name: "gvar", scope: !0, file: !1, line: 1, type: !13, isLocal: false, isDefinition: true)
+!22 = !{!20}
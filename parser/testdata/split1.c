@@ -0,0 +1,2 @@
+static int helper(int x) { return x + 1; }
+int f1(int x) { return helper(x); }
@@ -0,0 +1,2 @@
+static int helper(int x) { return x + 2; }
+int f2(int x) { return helper(x); }
@@ -36,28 +36,33 @@
 
 package dwarfparser
 
-import (
-	"debug/elf"
-	"fmt"
-)
+import "fmt"
 
 // ReadCoverPoints finds all coverage points (calls of __sanitizer_cov_trace_*) in the object file.
 // Currently it is [amd64|arm64]-specific: looks for opcode and correct offset.
 // Running objdump on the whole object file is too slow.
 func FindAllCoverPoints(path string) ([2][]uint64, error) {
+	b, release, err := openCached(path)
+	if err != nil {
+		return [2][]uint64{}, err
+	}
+	defer release()
+	return b.FindAllCoverPoints()
+}
+
+func (b *Binary) FindAllCoverPoints() ([2][]uint64, error) {
 	var pcs [2][]uint64
-	info, err := GetTracePCInfo(path)
+	info, err := b.GetTracePCInfo()
 	if err != nil {
 		return pcs, err
 	}
 	if info.tracePC == 0 {
 		return pcs, fmt.Errorf("no __sanitizer_cov_trace_pc symbol in the object file")
 	}
-	f, err := elf.Open(path)
+	f, err := b.File()
 	if err != nil {
 		return pcs, err
 	}
-	defer f.Close()
 	s, err := GetSectionByName(f, ".text")
 	if err != nil {
 		return pcs, err
@@ -65,7 +70,7 @@ func FindAllCoverPoints(path string) ([2][]uint64, error) {
 	if s == nil {
 		return pcs, fmt.Errorf("no .text section in the object file")
 	}
-	data, err := s.Data()
+	data, err := b.sectionData(s)
 	if err != nil {
 		return pcs, err
 	}
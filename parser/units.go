@@ -0,0 +1,390 @@
+// =============================================================================
+//  @@-COPYRIGHT-START-@@
+//
+//  Copyright (c) 2024, Qualcomm Innovation Center, Inc. All rights reserved.
+//
+//  Redistribution and use in source and binary forms, with or without
+//  modification, are permitted provided that the following conditions are met:
+//
+//  1. Redistributions of source code must retain the above copyright notice,
+//     this list of conditions and the following disclaimer.
+//
+//  2. Redistributions in binary form must reproduce the above copyright notice,
+//     this list of conditions and the following disclaimer in the documentation
+//     and/or other materials provided with the distribution.
+//
+//  3. Neither the name of the copyright holder nor the names of its contributors
+//     may be used to endorse or promote products derived from this software
+//     without specific prior written permission.
+//
+//  THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS"
+//  AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE
+//  IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE
+//  ARE DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT HOLDER OR CONTRIBUTORS BE
+//  LIABLE FOR ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR
+//  CONSEQUENTIAL DAMAGES (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF
+//  SUBSTITUTE GOODS OR SERVICES; LOSS OF USE, DATA, OR PROFITS; OR BUSINESS
+//  INTERRUPTION) HOWEVER CAUSED AND ON ANY THEORY OF LIABILITY, WHETHER IN
+//  CONTRACT, STRICT LIABILITY, OR TORT (INCLUDING NEGLIGENCE OR OTHERWISE)
+//  ARISING IN ANY WAY OUT OF THE USE OF THIS SOFTWARE, EVEN IF ADVISED OF THE
+//  POSSIBILITY OF SUCH DAMAGE.
+//
+//  SPDX-License-Identifier: BSD-3-Clause
+//
+//  @@-COPYRIGHT-END-@@
+// =============================================================================
+
+package dwarfparser
+
+import (
+	"bytes"
+	"debug/dwarf"
+	"encoding/hex"
+	"fmt"
+	"iter"
+	"path/filepath"
+	"sort"
+)
+
+// Attributes missing in debug/dwarf.
+const (
+	attrDWOName    dwarf.Attr = 0x76
+	attrGNUDWOName dwarf.Attr = 0x2130
+
+	attrMIPSLinkageName dwarf.Attr = 0x2007
+)
+
+// isUnit reports whether tag is the tag of a unit DIE.
+func isUnit(tag dwarf.Tag) bool {
+	switch tag {
+	case dwarf.TagCompileUnit, dwarf.TagPartialUnit, dwarf.TagTypeUnit, dwarf.TagSkeletonUnit:
+		return true
+	}
+	return false
+}
+
+// Units walks all units of .debug_info in order: compile, partial, type
+// and skeleton units. Unlike CompileUnits it skips none of them. Name falls
+// back to the DWO name for skeleton units.
+func (b *Binary) Units() iter.Seq2[*DWARFCompileUnit, error] {
+	return func(yield func(*DWARFCompileUnit, error) bool) {
+		di, err := b.DWARF()
+		if err != nil {
+			yield(nil, err)
+			return
+		}
+		for r := di.Reader(); ; {
+			ent, err := r.Next()
+			if err != nil {
+				yield(nil, err)
+				return
+			}
+			if ent == nil {
+				return
+			}
+			r.SkipChildren()
+			if !isUnit(ent.Tag) {
+				yield(nil, &DIEError{Offset: ent.Offset, Msg: fmt.Sprintf("unexpected tag %v on top level", ent.Tag)})
+				return
+			}
+			cu, err := b.newUnit(di, ent)
+			if !yield(cu, err) || err != nil {
+				return
+			}
+		}
+	}
+}
+
+func (b *Binary) newUnit(di *dwarf.Data, ent *dwarf.Entry) (*DWARFCompileUnit, error) {
+	name, err := b.stringAttr(ent, dwarf.AttrName)
+	if err != nil {
+		return nil, err
+	}
+	for _, attr := range []dwarf.Attr{attrDWOName, attrGNUDWOName} {
+		if name == "" {
+			if name, err = b.stringAttr(ent, attr); err != nil {
+				return nil, err
+			}
+		}
+	}
+	compDir, err := b.stringAttr(ent, dwarf.AttrCompDir)
+	if err != nil {
+		return nil, err
+	}
+	var ranges [][2]uint64
+	if ent.Tag != dwarf.TagTypeUnit {
+		if ranges, err = di.Ranges(ent); err != nil {
+			return nil, err
+		}
+	}
+	return &DWARFCompileUnit{
+		Binary:   b,
+		FilePath: b.Path,
+		Dwarf:    di,
+		Entry:    ent,
+		Name:     name,
+		CompDir:  compDir,
+		Ranges:   ranges,
+	}, nil
+}
+
+// allUnits returns the units of b sorted by offset.
+func (b *Binary) allUnits() ([]*DWARFCompileUnit, error) {
+	b.mu.Lock()
+	units := b.units
+	b.mu.Unlock()
+	if units != nil {
+		return units, nil
+	}
+	for u, err := range b.Units() {
+		if err != nil {
+			return nil, err
+		}
+		units = append(units, u)
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.units == nil {
+		b.units = units
+	}
+	return b.units, nil
+}
+
+// unitOffsets returns the offsets of the unit DIEs of b in order. Unlike
+// allUnits, it keeps nothing else of the units.
+func (b *Binary) unitOffsets() ([]dwarf.Offset, error) {
+	b.mu.Lock()
+	offs := b.unitOffs
+	b.mu.Unlock()
+	if offs != nil {
+		return offs, nil
+	}
+	offs = []dwarf.Offset{}
+	for u, err := range b.Units() {
+		if err != nil {
+			return nil, err
+		}
+		offs = append(offs, u.Entry.Offset)
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.unitOffs == nil {
+		b.unitOffs = offs
+	}
+	return b.unitOffs, nil
+}
+
+// unitOf returns the unit containing the DIE at off. Unless allUnits was
+// called, that is cu if it is the one and else a unit read anew, so that
+// following references doesn't keep every unit of b.
+func (b *Binary) unitOf(cu *DWARFCompileUnit, off dwarf.Offset) (*DWARFCompileUnit, error) {
+	b.mu.Lock()
+	units := b.units
+	b.mu.Unlock()
+	if units != nil {
+		i := sort.Search(len(units), func(i int) bool {
+			return units[i].Entry.Offset > off
+		})
+		if i == 0 {
+			return nil, &DIEError{Offset: off, Msg: "reference before the first unit"}
+		}
+		return units[i-1], nil
+	}
+	offs, err := b.unitOffsets()
+	if err != nil {
+		return nil, err
+	}
+	i := sort.Search(len(offs), func(i int) bool {
+		return offs[i] > off
+	})
+	if i == 0 {
+		return nil, &DIEError{Offset: off, Msg: "reference before the first unit"}
+	}
+	if cu.Binary == b && cu.Entry.Offset == offs[i-1] {
+		return cu, nil
+	}
+	di, err := b.DWARF()
+	if err != nil {
+		return nil, err
+	}
+	r := di.Reader()
+	r.Seek(offs[i-1])
+	ent, err := r.Next()
+	if err != nil {
+		return nil, err
+	}
+	if ent == nil {
+		return nil, &DIEError{Offset: off, Msg: "reference past the last unit"}
+	}
+	return b.newUnit(di, ent)
+}
+
+// refEntry returns the DIE referenced by attr of ent, a DIE of cu, and the
+// unit containing it, which belongs to the supplementary file for
+// DW_FORM_GNU_ref_alt and DW_FORM_ref_sup*. It returns nils if ent has no
+// attr.
+func (cu *DWARFCompileUnit) refEntry(ent *dwarf.Entry, attr dwarf.Attr) (*DWARFCompileUnit, *dwarf.Entry, error) {
+	field := ent.AttrField(attr)
+	if field == nil {
+		return nil, nil, nil
+	}
+	b := cu.Binary
+	off, ok := field.Val.(dwarf.Offset)
+	if !ok {
+		alt, ok := altOffset(field)
+		if !ok || (field.Class != dwarf.ClassReference && field.Class != dwarf.ClassReferenceAlt) {
+			return nil, nil, &DIEError{Offset: ent.Offset, Msg: fmt.Sprintf("%v is not a reference", attr)}
+		}
+		var err error
+		if b, err = cu.Binary.supplementary(ent, attr); err != nil {
+			return nil, nil, err
+		}
+		off = dwarf.Offset(alt)
+	}
+	u, err := b.unitOf(cu, off)
+	if err != nil {
+		return nil, nil, err
+	}
+	target, err := u.getEntryByOffset(off)
+	if err != nil {
+		return nil, nil, err
+	}
+	if target == nil {
+		return nil, nil, &DIEError{Offset: ent.Offset, Msg: fmt.Sprintf("dangling %v 0x%x", attr, off)}
+	}
+	return u, target, nil
+}
+
+// stringAttr returns the string value of attr of ent, a DIE of b, or "" if
+// there is none. DW_FORM_GNU_strp_alt and DW_FORM_strp_sup are read from
+// the supplementary file.
+func (b *Binary) stringAttr(ent *dwarf.Entry, attr dwarf.Attr) (string, error) {
+	field := ent.AttrField(attr)
+	if field == nil {
+		return "", nil
+	}
+	if s, ok := field.Val.(string); ok {
+		return s, nil
+	}
+	off, ok := altOffset(field)
+	if !ok || (field.Class != dwarf.ClassString && field.Class != dwarf.ClassStringAlt) {
+		return "", &DIEError{Offset: ent.Offset, Msg: fmt.Sprintf("%v is not a string", attr)}
+	}
+	alt, err := b.supplementary(ent, attr)
+	if err != nil {
+		return "", err
+	}
+	strs, err := alt.debugStr()
+	if err != nil {
+		return "", err
+	}
+	return strAt(strs, off), nil
+}
+
+// altOffset returns the offset of a reference or string held in the
+// supplementary file.
+func altOffset(field *dwarf.Field) (uint64, bool) {
+	switch v := field.Val.(type) {
+	case int64:
+		return uint64(v), true
+	case uint32:
+		return uint64(v), true
+	case uint64:
+		return v, field.Class != dwarf.ClassReferenceSig
+	}
+	return 0, false
+}
+
+func (b *Binary) supplementary(ent *dwarf.Entry, attr dwarf.Attr) (*Binary, error) {
+	alt, err := b.altBinary()
+	if err != nil {
+		return nil, err
+	}
+	if alt == nil {
+		return nil, &DIEError{Offset: ent.Offset, Msg: fmt.Sprintf("%v refers to a supplementary file but there is no .gnu_debugaltlink", attr)}
+	}
+	return alt, nil
+}
+
+// altBinary returns the supplementary file of b written by dwz, named by
+// .gnu_debugaltlink or .debug_sup, or nil if b has none. A relative name is
+// taken from the directory of the file holding the DWARF of b. It is opened
+// once and closed together with b.
+func (b *Binary) altBinary() (*Binary, error) {
+	b.mu.Lock()
+	if b.altOpened {
+		defer b.mu.Unlock()
+		return b.alt, b.altErr
+	}
+	b.mu.Unlock()
+	alt, err := b.openAlt()
+	if err != nil {
+		err = fmt.Errorf("%v: supplementary file: %w", b.Path, err)
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.altOpened {
+		if alt != nil {
+			alt.Close()
+		}
+		return b.alt, b.altErr
+	}
+	b.alt, b.altErr, b.altOpened = alt, err, true
+	return alt, err
+}
+
+func (b *Binary) openAlt() (*Binary, error) {
+	db, f, err := b.debugFile()
+	if err != nil {
+		return nil, err
+	}
+	var name string
+	var buildID []byte
+	if s := f.Section(".gnu_debugaltlink"); s != nil {
+		data, err := db.sectionData(s)
+		if err != nil {
+			return nil, err
+		}
+		i := bytes.IndexByte(data, 0)
+		if i < 0 {
+			return nil, fmt.Errorf("malformed .gnu_debugaltlink")
+		}
+		name, buildID = string(data[:i]), data[i+1:]
+	} else if s := debugSection(f, ".debug_sup"); s != nil {
+		data, err := db.sectionData(s)
+		if err != nil {
+			return nil, err
+		}
+		// version, is_supplementary, filename, checksum
+		i := -1
+		if len(data) > 3 {
+			i = bytes.IndexByte(data[3:], 0)
+		}
+		if i < 0 {
+			return nil, fmt.Errorf("malformed .debug_sup")
+		}
+		name = string(data[3 : 3+i])
+	}
+	if name == "" {
+		return nil, nil
+	}
+	if !filepath.IsAbs(name) {
+		name = filepath.Join(filepath.Dir(db.Path), name)
+	}
+	open := Open
+	if b.mapping != nil {
+		open = OpenMmap
+	}
+	alt, err := open(name)
+	if err != nil {
+		return nil, err
+	}
+	if len(buildID) != 0 {
+		id, err := alt.BuildID()
+		if err != nil || id != hex.EncodeToString(buildID) {
+			alt.Close()
+			return nil, fmt.Errorf("%v: build-id %v, want %x", name, id, buildID)
+		}
+	}
+	return alt, nil
+}